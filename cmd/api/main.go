@@ -1,16 +1,28 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kitamersion/go-goservice/internal/api/dto"
 	"github.com/kitamersion/go-goservice/internal/api/handlers"
+	"github.com/kitamersion/go-goservice/internal/api/middleware"
+	"github.com/kitamersion/go-goservice/internal/api/openapi"
+	"github.com/kitamersion/go-goservice/internal/api/problem"
 	"github.com/kitamersion/go-goservice/internal/config"
 	"github.com/kitamersion/go-goservice/internal/database"
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
+	"github.com/kitamersion/go-goservice/internal/domain/idempotency"
 	"github.com/kitamersion/go-goservice/internal/domain/repositories"
 	"github.com/kitamersion/go-goservice/internal/domain/services"
 	"github.com/kitamersion/go-goservice/internal/events"
+	"github.com/kitamersion/go-goservice/internal/events/outbox"
 	"github.com/kitamersion/go-goservice/internal/events/producer"
+	"github.com/kitamersion/go-goservice/internal/events/schema"
+	"github.com/kitamersion/go-goservice/internal/imports"
+	"github.com/kitamersion/go-goservice/internal/server"
 	"github.com/sirupsen/logrus"
 )
 
@@ -41,35 +53,126 @@ func main() {
 		logger.WithError(err).Fatal("Failed to initialize Kafka topics")
 	}
 
-	// Initialize producer
-	eventProducer := producer.NewProducer(&cfg.Kafka, logger)
-	defer eventProducer.Close()
-
 	// Initialize repositories and services
 	userRepo := repositories.NewUserRepository(db)
-	userService := services.NewUserService(userRepo, eventProducer)
+	eventRepo := repositories.NewEventRepository(db)
+	transactor := repositories.NewTransactor(db, userRepo, eventRepo)
+	userService := services.NewUserService(userRepo, transactor)
+
+	// Background workers and resources, stopped in order on shutdown
+	lifecycle := server.NewLifecycle(&cfg.Server, logger)
+
+	// Optionally relay outbox events to Kafka in the background; cmd/relay does this otherwise
+	if cfg.Outbox.EmbeddedRelay {
+		schemaRegistry, err := schema.NewRegistryFromConfig(&cfg.Schema, db)
+		if err != nil {
+			log.Fatal("Failed to set up schema registry:", err)
+		}
+		eventProducer := producer.NewProducer(&cfg.Kafka, logger, producer.WithSchemaRegistry(schemaRegistry))
+		lifecycle.OnShutdown("Kafka producer", eventProducer.Close)
+
+		relayLock, err := database.NewAdvisoryLock(db, outbox.LockKey)
+		if err != nil {
+			log.Fatal("Failed to set up outbox lock:", err)
+		}
+		relay := outbox.NewRelay(eventRepo, transactor, eventProducer, relayLock, &cfg.Outbox, logger)
+		lifecycle.Go("outbox relay", relay.Start)
+	}
+
+	// Replay responses of retried requests that carry an Idempotency-Key
+	idempotencyStore := idempotency.NewStore(repositories.NewIdempotencyRepository(db), cfg.Idempotency.TTL, logger)
+	lifecycle.Go("idempotency cleanup", func(ctx context.Context) error {
+		idempotencyStore.StartCleanup(ctx, cfg.Idempotency.CleanupInterval)
+		return nil
+	})
+
+	// Run bulk user imports in the background
+	importer := imports.NewImporter(userService, repositories.NewImportJobRepository(db), &cfg.Imports, logger)
+	lifecycle.Go("user importer", importer.Start)
+
+	// The pool closes last, after workers that still write to it have stopped
+	lifecycle.OnShutdown("database pool", func() error { return database.Close(db) })
 
 	// Initialize handlers
 	userHandler := handlers.NewUserHandler(userService)
+	eventHandler := handlers.NewEventHandler(services.NewEventService(eventRepo))
+	importHandler := handlers.NewImportHandler(importer, cfg.Imports.MaxBodyBytes)
 
 	// Setup Gin router
+	r := newRouter(userHandler, eventHandler, importHandler, idempotencyStore)
+
+	// Serve until SIGINT/SIGTERM, then drain requests and shut down in order
+	logger.Info("Starting API server on port ", cfg.Server.Port)
+	srv := server.NewHTTPServer(&cfg.Server, ":"+cfg.Server.Port, r)
+	if err := lifecycle.Run(srv); err != nil {
+		log.Fatal("API server stopped:", err)
+	}
+}
+
+// newRouter registers every route of the API. Routes added here must also be
+// described in openapi.Build and listed in contracts; main_test.go checks
+// both.
+func newRouter(userHandler *handlers.UserHandler, eventHandler *handlers.EventHandler, importHandler *handlers.ImportHandler, idempotencyStore *idempotency.Store) *gin.Engine {
 	r := gin.Default()
 
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
+		c.JSON(200, healthResponse{Status: "ok"})
+	})
+
+	// API documentation
+	specHandler, err := openapi.SpecHandler(openapi.Build())
+	if err != nil {
+		log.Fatal("Failed to render OpenAPI document:", err)
+	}
+	r.GET(openapi.SpecPath, specHandler)
+	r.GET(openapi.DocsPath, openapi.DocsHandler)
+
+	// Unknown routes get the same problem+json body as handler errors
+	r.NoRoute(func(c *gin.Context) {
+		problem.Write(c, http.StatusNotFound, apperrors.KindNotFound, "route not found")
 	})
 
 	// API routes
 	api := r.Group("/api/v1")
 	{
-		api.POST("/users", userHandler.CreateUser)
+		api.GET("/users", userHandler.ListUsers)
+		api.POST("/users", middleware.Idempotency(idempotencyStore), userHandler.CreateUser)
 		api.GET("/users/:id", userHandler.GetUser)
-	}
+		api.PUT("/users/:id", userHandler.ReplaceUser)
+		api.PATCH("/users/:id", userHandler.PatchUser)
+		api.DELETE("/users/:id", userHandler.DeleteUser)
 
-	// Start server
-	logger.Info("Starting API server on port ", cfg.Server.Port)
-	if err := r.Run(":" + cfg.Server.Port); err != nil {
-		log.Fatal("Failed to start server:", err)
+		// Gin reads ":import" as a parameter; CustomMethod only lets the literal path through
+		api.POST("/users:import", handlers.CustomMethod("import", importHandler.ImportUsers))
+		api.GET("/jobs/:id", importHandler.GetJob)
+
+		api.GET("/events", eventHandler.ListEvents)
 	}
+
+	return r
+}
+
+type healthResponse struct {
+	Status string `json:"status"`
+}
+
+// contracts names the bodies each route's handler reads and writes, which
+// openapi.Verify compares with the document.
+var contracts = map[string]openapi.Contract{
+	"GET /health":             {Responses: map[int]any{http.StatusOK: healthResponse{}}},
+	"GET " + openapi.SpecPath: {Responses: map[int]any{http.StatusOK: map[string]any{}}},
+	"GET " + openapi.DocsPath: {},
+
+	"GET /api/v1/users":        {Responses: map[int]any{http.StatusOK: dto.ListUsersResponse{}}},
+	"POST /api/v1/users":       {Request: dto.CreateUserRequest{}, Responses: map[int]any{http.StatusCreated: dto.UserResponse{}}},
+	"GET /api/v1/users/:id":    {Responses: map[int]any{http.StatusOK: dto.UserResponse{}}},
+	"PUT /api/v1/users/:id":    {Request: dto.ReplaceUserRequest{}, Responses: map[int]any{http.StatusOK: dto.UserResponse{}}},
+	"PATCH /api/v1/users/:id":  {Request: dto.PatchUserRequest{}, Responses: map[int]any{http.StatusOK: dto.UserResponse{}}},
+	"DELETE /api/v1/users/:id": {},
+
+	"POST /api/v1/users:import": {Responses: map[int]any{http.StatusAccepted: dto.ImportJobResponse{}}}, // the body is NDJSON or CSV
+	"GET /api/v1/jobs/:id":      {Responses: map[int]any{http.StatusOK: dto.ImportJobResponse{}}},
+
+	"GET /api/v1/events": {Responses: map[int]any{http.StatusOK: dto.ListEventsResponse{}}},
 }
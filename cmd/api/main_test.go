@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kitamersion/go-goservice/internal/api/handlers"
+	"github.com/kitamersion/go-goservice/internal/api/openapi"
+)
+
+// TestOpenAPIDocument fails when a route, path parameter or JSON body is
+// missing from the OpenAPI document or differs from what the handler uses.
+func TestOpenAPIDocument(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Registering routes does not touch the services, so none are needed
+	r := newRouter(handlers.NewUserHandler(nil), handlers.NewEventHandler(nil), handlers.NewImportHandler(nil, 0), nil)
+	if err := openapi.Verify(openapi.Build(), r.Routes(), contracts); err != nil {
+		t.Fatalf("OpenAPI document is out of date: %v", err)
+	}
+}
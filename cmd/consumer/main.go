@@ -8,9 +8,12 @@ import (
 	"syscall"
 
 	"github.com/kitamersion/go-goservice/internal/config"
+	"github.com/kitamersion/go-goservice/internal/database"
+	"github.com/kitamersion/go-goservice/internal/domain/repositories"
 	"github.com/kitamersion/go-goservice/internal/events"
 	"github.com/kitamersion/go-goservice/internal/events/consumer"
 	"github.com/kitamersion/go-goservice/internal/events/consumer/handlers"
+	"github.com/kitamersion/go-goservice/internal/events/dedup"
 
 	"github.com/sirupsen/logrus"
 )
@@ -33,8 +36,31 @@ func main() {
 		logger.WithError(err).Fatal("Failed to initialize Kafka topics")
 	}
 
+	// Database connection
+	db, err := database.NewConnection(&cfg.Database)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	// Run migrations
+	if err := database.RunMigrations(db); err != nil {
+		log.Fatal("Failed to run migrations:", err)
+	}
+
+	// Setup graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var consumerOpts []consumer.Option
+	if cfg.Kafka.Dedup.Enabled {
+		processedEventRepo := repositories.NewProcessedEventRepository(db)
+		dedupStore := dedup.NewPostgresStore(db, processedEventRepo, cfg.Kafka.ConsumerGroups.UserConsumer, cfg.Kafka.Dedup.TTL, logger)
+		go dedupStore.StartCleanup(ctx, cfg.Kafka.Dedup.CleanupInterval)
+		consumerOpts = append(consumerOpts, consumer.WithDedupStore(dedupStore))
+	}
+
 	// TODO: make this generic for additional consumers to get registered
-	kafkaConsumer := consumer.NewConsumer(&cfg.Kafka, logger)
+	kafkaConsumer := consumer.NewConsumer(&cfg.Kafka, logger, consumerOpts...)
 	defer kafkaConsumer.Close()
 
 	// Initialize event handlers
@@ -46,10 +72,6 @@ func main() {
 	kafkaConsumer.RegisterHandler("userpb.UserUpdated", userHandlers.HandleUserUpdated)
 	kafkaConsumer.RegisterHandler("userpb.UserDeleted", userHandlers.HandleUserDeleted)
 
-	// Setup graceful shutdown
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
 	go func() {
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
@@ -13,10 +14,15 @@ import (
 	"github.com/kitamersion/go-goservice/graph"
 	"github.com/kitamersion/go-goservice/internal/config"
 	"github.com/kitamersion/go-goservice/internal/database"
+	"github.com/kitamersion/go-goservice/internal/domain/idempotency"
 	"github.com/kitamersion/go-goservice/internal/domain/repositories"
 	"github.com/kitamersion/go-goservice/internal/domain/services"
 	"github.com/kitamersion/go-goservice/internal/events"
+	"github.com/kitamersion/go-goservice/internal/events/hub"
+	"github.com/kitamersion/go-goservice/internal/events/outbox"
 	"github.com/kitamersion/go-goservice/internal/events/producer"
+	"github.com/kitamersion/go-goservice/internal/events/schema"
+	"github.com/kitamersion/go-goservice/internal/server"
 	"github.com/sirupsen/logrus"
 	"github.com/vektah/gqlparser/v2/ast"
 )
@@ -55,35 +61,99 @@ func main() {
 		logger.WithError(err).Fatal("Failed to initialize Kafka topics")
 	}
 
-	// Initialize producer
-	eventProducer := producer.NewProducer(&cfg.Kafka, logger)
-	defer eventProducer.Close()
-
 	// Initialize repositories and services
 	userRepo := repositories.NewUserRepository(db)
-	userService := services.NewUserService(userRepo, eventProducer)
+	eventRepo := repositories.NewEventRepository(db)
+	transactor := repositories.NewTransactor(db, userRepo, eventRepo)
+	userService := services.NewUserService(userRepo, transactor)
+
+	// Background workers and resources, stopped in order on shutdown
+	lifecycle := server.NewLifecycle(&cfg.Server, logger)
+
+	// Optionally relay outbox events to Kafka in the background; cmd/relay does this otherwise
+	if cfg.Outbox.EmbeddedRelay {
+		schemaRegistry, err := schema.NewRegistryFromConfig(&cfg.Schema, db)
+		if err != nil {
+			log.Fatal("Failed to set up schema registry:", err)
+		}
+		eventProducer := producer.NewProducer(&cfg.Kafka, logger, producer.WithSchemaRegistry(schemaRegistry))
+		lifecycle.OnShutdown("Kafka producer", eventProducer.Close)
+
+		relayLock, err := database.NewAdvisoryLock(db, outbox.LockKey)
+		if err != nil {
+			log.Fatal("Failed to set up outbox lock:", err)
+		}
+		relay := outbox.NewRelay(eventRepo, transactor, eventProducer, relayLock, &cfg.Outbox, logger)
+		lifecycle.Go("outbox relay", relay.Start)
+	}
+
+	// Replay results of retried mutations that carry an idempotencyKey
+	idempotencyStore := idempotency.NewStore(repositories.NewIdempotencyRepository(db), cfg.Idempotency.TTL, logger)
+	lifecycle.Go("idempotency cleanup", func(ctx context.Context) error {
+		idempotencyStore.StartCleanup(ctx, cfg.Idempotency.CleanupInterval)
+		return nil
+	})
+
+	// Fan live user events out to GraphQL subscriptions
+	eventHub := hub.NewHub(&cfg.Kafka, &cfg.Graph.Subscriptions, logger)
+	lifecycle.Go("live event hub", eventHub.Start)
+
+	// The pool closes last, after workers that still write to it have stopped
+	lifecycle.OnShutdown("database pool", func() error { return database.Close(db) })
 
 	// Initialize GraphQL resolver
 	gqlResolver := &graph.Resolver{
-		UserService: userService,
+		UserService:  userService,
+		EventService: services.NewEventService(eventRepo),
+		Idempotency:  idempotencyStore,
+		Events:       eventHub,
 	}
 
 	srv := handler.New(graph.NewExecutableSchema(graph.Config{Resolvers: gqlResolver}))
+	srv.SetErrorPresenter(graph.NewErrorPresenter(logger))
 
 	srv.AddTransport(transport.Options{})
 	srv.AddTransport(transport.GET{})
 	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: cfg.Graph.Subscriptions.PingInterval, // graphql-ws
+		PingPongInterval:      cfg.Graph.Subscriptions.PingInterval, // graphql-transport-ws, drops clients that stop answering
+	})
 
 	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
 
-	srv.Use(extension.Introspection{})
-	srv.Use(extension.AutomaticPersistedQuery{
-		Cache: lru.New[string](100),
-	})
+	// Reject operations that are too deep or costly, and charge each client's budget
+	limits := graph.NewLimits(&cfg.Graph.Limits, logger)
+	srv.Use(limits)
+
+	if !cfg.Graph.DisableIntrospection {
+		srv.Use(extension.Introspection{})
+	}
 
-	http.Handle("/playground", playground.Handler("GraphQL playground", "/graphql"))
-	http.Handle("/graphql", srv)
+	// Either remember any query sent with its hash, or only run the manifest's
+	if cfg.Graph.PersistedQueries.Allowlist {
+		allowlist, err := graph.LoadPersistedQueries(cfg.Graph.PersistedQueries.Manifest, logger)
+		if err != nil {
+			log.Fatal("Failed to load persisted queries:", err)
+		}
+		logger.Infof("Only running the %d operations of %s", allowlist.Len(), cfg.Graph.PersistedQueries.Manifest)
+		srv.Use(extension.AutomaticPersistedQuery{Cache: allowlist})
+		srv.Use(allowlist)
+	} else {
+		srv.Use(extension.AutomaticPersistedQuery{
+			Cache: lru.New[string](100),
+		})
+	}
 
-	log.Printf("connect to http://localhost:%s/playground for GraphQL playground", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	mux := http.NewServeMux()
+	if !cfg.Graph.DisablePlayground {
+		mux.Handle("/playground", playground.Handler("GraphQL playground", "/graphql"))
+		log.Printf("connect to http://localhost:%s/playground for GraphQL playground", port)
+	}
+	mux.Handle("/graphql", server.AllowWebSockets(limits.ClientMiddleware(graph.LoaderMiddleware(userService, srv))))
+
+	// Serve until SIGINT/SIGTERM, then drain requests and shut down in order
+	if err := lifecycle.Run(server.NewHTTPServer(&cfg.Server, ":"+port, mux)); err != nil {
+		log.Fatal("GraphQL server stopped:", err)
+	}
 }
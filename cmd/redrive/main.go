@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kitamersion/go-goservice/internal/config"
+	"github.com/kitamersion/go-goservice/internal/events/dlq"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// Re-drives dead-lettered messages back onto the topic they were consumed from.
+func main() {
+	maxMessages := flag.Int("max", 0, "maximum number of messages to re-drive (0 = all)")
+	idleTimeout := flag.Duration("idle-timeout", 10*time.Second, "stop once no message arrived for this long")
+	dryRun := flag.Bool("dry-run", false, "log messages without re-driving them")
+	flag.Parse()
+
+	// Load configuration
+	cfg, err := config.LoadConfig("./configs")
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	// Setup logger
+	logger := logrus.New()
+	logger.SetLevel(logrus.InfoLevel)
+
+	opts := dlq.RedriveOptions{
+		DefaultTopic: cfg.Kafka.Topics.UserEvents,
+		Max:          *maxMessages,
+		IdleTimeout:  *idleTimeout,
+		DryRun:       *dryRun,
+	}
+	// run returns instead of exiting, so its deferred closes always happen
+	if err := run(cfg, opts, logger); err != nil {
+		logger.WithError(err).Error("Re-drive failed")
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config.Config, opts dlq.RedriveOptions, logger *logrus.Logger) error {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     cfg.Kafka.Brokers,
+		Topic:       cfg.Kafka.Topics.UserEventsDLQ,
+		GroupID:     cfg.Kafka.ConsumerGroups.DLQRedrive,
+		StartOffset: kafka.FirstOffset,
+		MinBytes:    1,
+		MaxBytes:    10e6,
+	})
+	defer reader.Close()
+
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Kafka.Brokers...),
+		Balancer:     &kafka.Hash{}, // original key -> same partition as the rest of its aggregate
+		RequiredAcks: kafka.RequireAll,
+		BatchSize:    1,
+	}
+	defer writer.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	logger.Infof("Re-driving messages from %s", cfg.Kafka.Topics.UserEventsDLQ)
+	redriven, err := dlq.Redrive(ctx, reader, writer, opts, logger)
+	if err != nil {
+		return fmt.Errorf("stopped after %d messages: %w", redriven, err)
+	}
+
+	logger.Infof("Re-drove %d messages", redriven)
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kitamersion/go-goservice/internal/config"
+	"github.com/kitamersion/go-goservice/internal/database"
+	"github.com/kitamersion/go-goservice/internal/domain/repositories"
+	"github.com/kitamersion/go-goservice/internal/events"
+	"github.com/kitamersion/go-goservice/internal/events/outbox"
+	"github.com/kitamersion/go-goservice/internal/events/producer"
+	"github.com/kitamersion/go-goservice/internal/events/schema"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	// Load configuration
+	cfg, err := config.LoadConfig("./configs")
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	// Setup logger
+	logger := logrus.New()
+	logger.SetLevel(logrus.InfoLevel)
+
+	// Database connection
+	db, err := database.NewConnection(&cfg.Database)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	// Run migrations
+	if err := database.RunMigrations(db); err != nil {
+		log.Fatal("Failed to run migrations:", err)
+	}
+
+	// Initialize Kafka topics (runs every startup, safe if already exists)
+	if err := events.InitKafkaTopics(&cfg.Kafka, logger); err != nil {
+		logger.WithError(err).Fatal("Failed to initialize Kafka topics")
+	}
+
+	// Initialize producer, stamping events with their registered schema IDs
+	schemaRegistry, err := schema.NewRegistryFromConfig(&cfg.Schema, db)
+	if err != nil {
+		log.Fatal("Failed to set up schema registry:", err)
+	}
+	eventProducer := producer.NewProducer(&cfg.Kafka, logger, producer.WithSchemaRegistry(schemaRegistry))
+	defer eventProducer.Close()
+
+	// Initialize repositories
+	userRepo := repositories.NewUserRepository(db)
+	eventRepo := repositories.NewEventRepository(db)
+	transactor := repositories.NewTransactor(db, userRepo, eventRepo)
+
+	relayLock, err := database.NewAdvisoryLock(db, outbox.LockKey)
+	if err != nil {
+		log.Fatal("Failed to set up outbox lock:", err)
+	}
+	metrics := outbox.NewMetrics(prometheus.DefaultRegisterer)
+	relay := outbox.NewRelay(eventRepo, transactor, eventProducer, relayLock, &cfg.Outbox, logger, outbox.WithMetrics(metrics))
+
+	// Setup graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		<-sigChan
+		logger.Info("Shutting down relay...")
+		cancel()
+	}()
+
+	// Expose relay metrics until the relay stops
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	metricsServer := &http.Server{
+		Addr:              ":" + cfg.Outbox.MetricsPort,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		logger.Info("Serving relay metrics on port ", cfg.Outbox.MetricsPort)
+		if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.WithError(err).Error("Metrics server stopped")
+		}
+	}()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			logger.WithError(err).Warn("Failed to shut down metrics server")
+		}
+	}()
+
+	// Start relaying
+	if err := relay.Start(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		logger.WithError(err).Error("Relay stopped with error")
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/kitamersion/go-goservice/internal/config"
+	"github.com/kitamersion/go-goservice/internal/database"
+	"github.com/kitamersion/go-goservice/internal/events/schema"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"gorm.io/gorm"
+
+	// Compiled-in event schemas, used when no descriptor set is given
+	_ "github.com/kitamersion/go-goservice/internal/events/proto/events/userpb"
+)
+
+const usage = `Usage: schema <check|register> [flags]
+
+  check     fail when a schema is incompatible with its registered versions
+  register  check, then register schemas that changed
+
+Flags:
+`
+
+func main() {
+	flags := flag.NewFlagSet("schema", flag.ExitOnError)
+	descriptorSet := flags.String("descriptor-set", "", "FileDescriptorSet from protoc --include_imports --descriptor_set_out (defaults to the compiled-in schemas)")
+	compatibility := flags.String("compatibility", "", "override schema_registry.compatibility (backward, forward, full, none)")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+
+	if len(os.Args) < 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	command := os.Args[1]
+	flags.Parse(os.Args[2:])
+
+	// Load configuration
+	cfg, err := config.LoadConfig("./configs")
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	if *compatibility != "" {
+		cfg.Schema.Compatibility = *compatibility
+	}
+
+	var db *gorm.DB
+	if cfg.Schema.Store == "postgres" {
+		if db, err = database.NewConnection(&cfg.Database); err != nil {
+			log.Fatal("Failed to connect to database:", err)
+		}
+		if err := database.RunMigrations(db); err != nil {
+			log.Fatal("Failed to run migrations:", err)
+		}
+	}
+	registry, err := schema.NewRegistryFromConfig(&cfg.Schema, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	files := protoregistry.GlobalFiles
+	if *descriptorSet != "" {
+		if files, err = schema.LoadDescriptorSet(*descriptorSet); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	messages := schema.Messages(files, cfg.Schema.Packages)
+	if len(messages) == 0 {
+		log.Fatalf("No messages found in packages %v", cfg.Schema.Packages)
+	}
+
+	failed := false
+	for _, message := range messages {
+		switch command {
+		case "check":
+			issues, err := registry.Check(message)
+			if err != nil {
+				log.Fatal(err)
+			}
+			failed = report(string(message.FullName()), issues) || failed
+		case "register":
+			version, issues, err := registry.Register(message)
+			if errors.Is(err, schema.ErrIncompatible) {
+				failed = report(string(message.FullName()), issues) || failed
+				continue
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("%s: schema id %d (version %d)\n", message.FullName(), version.ID, version.Version)
+		default:
+			flags.Usage()
+			os.Exit(2)
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// report prints the issues of one subject and tells whether there were any.
+func report(subject string, issues []schema.Issue) bool {
+	if len(issues) == 0 {
+		fmt.Printf("%s: compatible\n", subject)
+		return false
+	}
+
+	fmt.Printf("%s: incompatible\n", subject)
+	for _, issue := range issues {
+		fmt.Printf("  - %s\n", issue)
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package graph
+
+import (
+	"strings"
+
+	"github.com/kitamersion/go-goservice/graph/model"
+	"github.com/kitamersion/go-goservice/internal/api/dto"
+	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"github.com/kitamersion/go-goservice/internal/domain/repositories"
+	"github.com/kitamersion/go-goservice/internal/events/hub"
+)
+
+var hubEventTypes = map[model.UserEventType]string{
+	model.UserEventTypeCreated: hub.TypeUserCreated,
+	model.UserEventTypeUpdated: hub.TypeUserUpdated,
+	model.UserEventTypeDeleted: hub.TypeUserDeleted,
+}
+
+func newUser(user *entities.UserEntity) *model.User {
+	return &model.User{
+		ID:        user.ID.String(),
+		Name:      user.Name,
+		Email:     user.Email,
+		Version:   int32(user.Version),
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+}
+
+func newEvent(event *entities.Event) *model.Event {
+	return &model.Event{
+		ID:               event.ID.String(),
+		Type:             event.Type,
+		AggregateID:      event.AggregateID,
+		AggregateVersion: int32(event.AggregateVersion),
+		Payload:          event.Payload,
+		CreatedAt:        event.CreatedAt,
+		PublishedAt:      event.PublishedAt,
+	}
+}
+
+// userQuery validates the users filter and order with the same rules as the
+// REST listing.
+func userQuery(filter *model.UserFilter, orderBy *model.UserOrder) (repositories.UserQuery, error) {
+	var query dto.ListUsersQuery
+	if filter != nil {
+		query.EmailDomain = deref(filter.EmailDomain)
+		query.NamePrefix = deref(filter.NamePrefix)
+		query.CreatedAfter = filter.CreatedAfter
+		query.CreatedBefore = filter.CreatedBefore
+		query.Search = deref(filter.Search)
+	}
+	if orderBy != nil {
+		query.Sort = strings.ToLower(orderBy.Field.String())
+		if orderBy.Direction == model.OrderDirectionDesc {
+			query.Sort = "-" + query.Sort
+		}
+	}
+
+	query.Normalize()
+	if err := query.Validate(); err != nil {
+		return repositories.UserQuery{}, err
+	}
+	return query.UserQuery(), nil
+}
+
+// expectedVersions turns an optional expectedVersion argument into the version
+// list the user service checks; nil leaves the write unconditional.
+func expectedVersions(version *int32) []int64 {
+	if version == nil {
+		return nil
+	}
+	return []int64{int64(*version)}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func newUserEvent(event hub.Event) *model.UserEvent {
+	out := &model.UserEvent{
+		ID:         event.ID,
+		UserID:     event.UserID,
+		OccurredAt: event.OccurredAt,
+	}
+	for t, name := range hubEventTypes {
+		if name == event.Type {
+			out.Type = t
+		}
+	}
+	if event.Version > 0 {
+		sequence := int32(event.Version)
+		out.Sequence = &sequence
+	}
+	if event.Type == hub.TypeUserCreated {
+		out.Name = &event.Name
+		out.Email = &event.Email
+	}
+	return out
+}
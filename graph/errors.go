@@ -0,0 +1,49 @@
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
+	"github.com/sirupsen/logrus"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// NewErrorPresenter returns an error presenter that sets extensions.code from
+// the apperrors kind of a resolver error, plus extensions.fields for
+// validation errors. Internal errors are logged and their
+// message hidden from clients; errors raised by gqlgen itself (parsing,
+// validation) are passed through unchanged.
+func NewErrorPresenter(logger *logrus.Logger) graphql.ErrorPresenterFunc {
+	return func(ctx context.Context, err error) *gqlerror.Error {
+		gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+
+		var appErr *apperrors.Error
+		if errors.As(err, &appErr) {
+			gqlErr.Message = appErr.Message
+			setCode(gqlErr, appErr.Kind)
+			if len(appErr.Fields) > 0 {
+				gqlErr.Extensions["fields"] = appErr.Fields
+			}
+			return gqlErr
+		}
+
+		var existing *gqlerror.Error
+		if errors.As(err, &existing) && existing.Err == nil {
+			return gqlErr
+		}
+
+		logger.WithError(err).WithField("path", gqlErr.Path.String()).Error("GraphQL resolver failed")
+		gqlErr.Message = "internal server error"
+		setCode(gqlErr, apperrors.KindInternal)
+		return gqlErr
+	}
+}
+
+func setCode(gqlErr *gqlerror.Error, kind apperrors.Kind) {
+	if gqlErr.Extensions == nil {
+		gqlErr.Extensions = map[string]interface{}{}
+	}
+	gqlErr.Extensions["code"] = string(kind)
+}
@@ -0,0 +1,287 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/kitamersion/go-goservice/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const (
+	errDepthLimit      = "DEPTH_LIMIT_EXCEEDED"
+	errComplexityLimit = "COMPLEXITY_LIMIT_EXCEEDED"
+	errBudgetExceeded  = "COST_BUDGET_EXCEEDED"
+
+	// maxMultiplier keeps nested multipliers such as first from overflowing
+	// the cost; resolvers reject such page sizes anyway.
+	maxMultiplier = 10000
+)
+
+type clientKey struct{}
+
+// Limits rejects operations that are too deep or too costly, and charges the
+// cost of every accepted operation against a per-client budget that resets
+// each window.
+//
+// The cost of a field is the weight of its @cost directive (1 without one)
+// plus the cost of its selections. Introspection fields are free.
+type Limits struct {
+	cfg    *config.LimitsConfig
+	logger *logrus.Logger
+
+	mu        sync.Mutex
+	windows   map[string]*budgetWindow
+	lastSweep time.Time
+}
+
+type budgetWindow struct {
+	start time.Time
+	spent int
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = &Limits{}
+
+func NewLimits(cfg *config.LimitsConfig, logger *logrus.Logger) *Limits {
+	return &Limits{
+		cfg:     cfg,
+		logger:  logger,
+		windows: make(map[string]*budgetWindow),
+	}
+}
+
+func (l *Limits) ExtensionName() string {
+	return "Limits"
+}
+
+func (l *Limits) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// ClientMiddleware records who sent the request, for the budget: the value of
+// the configured header, or the remote host when it is unset or missing.
+func (l *Limits) ClientMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := ""
+		if l.cfg.ClientHeader != "" {
+			client = strings.TrimSpace(r.Header.Get(l.cfg.ClientHeader))
+		}
+		if client == "" {
+			client = r.RemoteAddr
+			if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				client = host
+			}
+		}
+		ctx := context.WithValue(r.Context(), clientKey{}, client)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (l *Limits) MutateOperationContext(ctx context.Context, opCtx *graphql.OperationContext) *gqlerror.Error {
+	op := opCtx.Doc.Operations.ForName(opCtx.OperationName)
+	if op == nil {
+		return nil // gqlgen reports the missing operation itself
+	}
+
+	depth, cost := measure(op.SelectionSet, opCtx.Variables)
+	client, _ := ctx.Value(clientKey{}).(string)
+
+	var err *gqlerror.Error
+	switch {
+	case l.cfg.MaxDepth > 0 && depth > l.cfg.MaxDepth:
+		err = gqlerror.Errorf("operation has depth %d, which exceeds the limit of %d", depth, l.cfg.MaxDepth)
+		errcode.Set(err, errDepthLimit)
+		err.Extensions["depth"] = depth
+		err.Extensions["limit"] = l.cfg.MaxDepth
+	case l.cfg.MaxComplexity > 0 && cost > l.cfg.MaxComplexity:
+		err = gqlerror.Errorf("operation has complexity %d, which exceeds the limit of %d", cost, l.cfg.MaxComplexity)
+		errcode.Set(err, errComplexityLimit)
+		err.Extensions["complexity"] = cost
+		err.Extensions["limit"] = l.cfg.MaxComplexity
+	default:
+		err = l.charge(client, cost)
+	}
+
+	if err != nil {
+		l.logger.WithFields(logrus.Fields{
+			"operation": opCtx.OperationName,
+			"client":    client,
+			"depth":     depth,
+			"cost":      cost,
+			"code":      err.Extensions["code"],
+		}).Warn("Rejected GraphQL operation")
+	}
+	return err
+}
+
+// charge spends cost from the client's budget, refusing operations that would
+// overdraw it. Rejected operations are not charged.
+func (l *Limits) charge(client string, cost int) *gqlerror.Error {
+	if l.cfg.Budget <= 0 || l.cfg.BudgetWindow <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+	window, ok := l.windows[client]
+	if !ok || now.Sub(window.start) >= l.cfg.BudgetWindow {
+		window = &budgetWindow{start: now}
+		l.windows[client] = window
+	}
+
+	if saturatingAdd(window.spent, cost) > l.cfg.Budget {
+		resetIn := window.start.Add(l.cfg.BudgetWindow).Sub(now)
+		err := gqlerror.Errorf("operation costs %d, but only %d of the budget of %d is left; it resets in %s",
+			cost, l.cfg.Budget-window.spent, l.cfg.Budget, resetIn.Round(time.Second))
+		errcode.Set(err, errBudgetExceeded)
+		err.Extensions["cost"] = cost
+		err.Extensions["remaining"] = l.cfg.Budget - window.spent
+		err.Extensions["budget"] = l.cfg.Budget
+		err.Extensions["retryAfter"] = int(math.Ceil(resetIn.Seconds()))
+		return err
+	}
+	window.spent += cost
+	return nil
+}
+
+// sweep drops expired windows, at most once per window, so clients that went
+// away do not accumulate. Callers hold mu.
+func (l *Limits) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.cfg.BudgetWindow {
+		return
+	}
+	for client, window := range l.windows {
+		if now.Sub(window.start) >= l.cfg.BudgetWindow {
+			delete(l.windows, client)
+		}
+	}
+	l.lastSweep = now
+}
+
+// measure returns the depth in field levels and the cost of a selection set,
+// following fragments.
+func measure(selections ast.SelectionSet, vars map[string]any) (depth, cost int) {
+	return measureScaled(selections, vars, 1, false)
+}
+
+// measureScaled is measure for the selections of a field with the given
+// multiplier, which scales every selection when the field is a list, and the
+// list fields (such as edges) otherwise. Fragments are scaled field by field,
+// like the selections they stand for.
+func measureScaled(selections ast.SelectionSet, vars map[string]any, multiplier int, isList bool) (depth, cost int) {
+	for _, selection := range selections {
+		var d, c int
+		switch sel := selection.(type) {
+		case *ast.Field:
+			d, c = measureField(sel, vars)
+			if isList || (sel.Definition != nil && sel.Definition.Type.Elem != nil) {
+				c = saturatingMul(c, multiplier)
+			}
+		case *ast.InlineFragment:
+			d, c = measureScaled(sel.SelectionSet, vars, multiplier, isList)
+		case *ast.FragmentSpread:
+			if sel.Definition != nil {
+				d, c = measureScaled(sel.Definition.SelectionSet, vars, multiplier, isList)
+			}
+		}
+		depth = max(depth, d)
+		cost = saturatingAdd(cost, c)
+	}
+	return depth, cost
+}
+
+func measureField(field *ast.Field, vars map[string]any) (depth, cost int) {
+	if strings.HasPrefix(field.Name, "__") || field.Definition == nil {
+		return 0, 0
+	}
+
+	weight, multipliers := fieldCost(field.Definition)
+	multiplier := 1
+	if len(multipliers) > 0 {
+		args := field.ArgumentMap(vars)
+		for _, name := range multipliers {
+			multiplier = saturatingMul(multiplier, multiplierValue(args[name]))
+		}
+	}
+
+	depth, cost = measureScaled(field.SelectionSet, vars, multiplier, field.Definition.Type.Elem != nil)
+	return depth + 1, saturatingAdd(weight, cost)
+}
+
+// fieldCost reads the @cost directive of a field definition.
+func fieldCost(def *ast.FieldDefinition) (weight int, multipliers []string) {
+	directive := def.Directives.ForName("cost")
+	if directive == nil {
+		return 1, nil
+	}
+
+	weight = 1
+	if arg := directive.Arguments.ForName("weight"); arg != nil && arg.Value != nil {
+		if n, err := strconv.Atoi(arg.Value.Raw); err == nil {
+			weight = n
+		}
+	}
+	if arg := directive.Arguments.ForName("multipliers"); arg != nil && arg.Value != nil {
+		for _, child := range arg.Value.Children {
+			multipliers = append(multipliers, child.Value.Raw)
+		}
+	}
+	return weight, multipliers
+}
+
+// multiplierValue converts an argument value, from a literal or a variable, to
+// a multiplier between 1 and maxMultiplier. Missing or invalid values count
+// as 1; the resolver rejects them.
+func multiplierValue(value any) int {
+	var n int64
+	switch v := value.(type) {
+	case int:
+		n = int64(v)
+	case int32:
+		n = int64(v)
+	case int64:
+		n = v
+	case float64:
+		n = int64(v)
+	case json.Number:
+		parsed, err := v.Int64()
+		if err != nil {
+			return 1
+		}
+		n = parsed
+	default:
+		return 1
+	}
+	return int(min(max(n, 1), maxMultiplier))
+}
+
+func saturatingAdd(a, b int) int {
+	if a > math.MaxInt-b {
+		return math.MaxInt
+	}
+	return a + b
+}
+
+func saturatingMul(a, b int) int {
+	if a != 0 && b > math.MaxInt/a {
+		return math.MaxInt
+	}
+	return a * b
+}
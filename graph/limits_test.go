@@ -0,0 +1,228 @@
+package graph
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/kitamersion/go-goservice/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const (
+	// Depth 4, cost 5 + 10 * (edges 1 + node 1 + id 1)
+	usersQuery     = `{ users(first: 10) { edges { node { id } } } }`
+	usersQueryCost = 35
+
+	introspectionQuery = `{ __schema { types { name fields { name type { name } } } } }`
+)
+
+// parseQuery validates query against the schema, as gqlgen does before the
+// limits run.
+func parseQuery(t *testing.T, query string) *ast.QueryDocument {
+	t.Helper()
+	doc, errs := gqlparser.LoadQuery(NewExecutableSchema(Config{}).Schema(), query)
+	if errs != nil {
+		t.Fatalf("invalid query %q: %v", query, errs)
+	}
+	return doc
+}
+
+func newTestLimits(cfg config.LimitsConfig) *Limits {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewLimits(&cfg, logger)
+}
+
+// runLimits measures query for client as the executor would, returning the
+// rejection if any.
+func runLimits(t *testing.T, l *Limits, client, query string, vars map[string]any) *gqlerror.Error {
+	t.Helper()
+	ctx := context.WithValue(context.Background(), clientKey{}, client)
+	return l.MutateOperationContext(ctx, &graphql.OperationContext{Doc: parseQuery(t, query), Variables: vars})
+}
+
+func TestMeasure(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		vars      map[string]any
+		wantDepth int
+		wantCost  int
+	}{
+		{
+			name:      "plain fields",
+			query:     `{ user(id: "1") { id name } }`,
+			wantDepth: 2,
+			wantCost:  3,
+		},
+		{
+			name:      "list multiplier",
+			query:     usersQuery,
+			wantDepth: 4,
+			wantCost:  usersQueryCost,
+		},
+		{
+			name:      "multiplier from a variable",
+			query:     `query($n: Int) { users(first: $n) { edges { node { id } } } }`,
+			vars:      map[string]any{"n": int64(50)},
+			wantDepth: 4,
+			wantCost:  5 + 50*3,
+		},
+		{
+			name:      "default multiplier",
+			query:     `{ users { edges { node { id } } } }`,
+			wantDepth: 4,
+			wantCost:  5 + 20*3,
+		},
+		{
+			name:      "fields beside the list are not multiplied",
+			query:     `{ users(first: 10) { totalCount edges { node { id name } } } }`,
+			wantDepth: 4,
+			wantCost:  5 + 1 + 10*4,
+		},
+		{
+			name:      "fragments are followed",
+			query:     `{ users(first: 10) { ...page } } fragment page on UserConnection { edges { node { id } } }`,
+			wantDepth: 4,
+			wantCost:  usersQueryCost,
+		},
+		{
+			name:      "lists in inline fragments are multiplied",
+			query:     `{ users(first: 10) { ... on UserConnection { edges { node { id } } } } }`,
+			wantDepth: 4,
+			wantCost:  usersQueryCost,
+		},
+		{
+			name:      "oversized multiplier is capped",
+			query:     `{ users(first: 1000000) { edges { node { id } } } }`,
+			wantDepth: 4,
+			wantCost:  5 + maxMultiplier*3,
+		},
+		{
+			name:      "introspection is free",
+			query:     introspectionQuery,
+			wantDepth: 0,
+			wantCost:  0,
+		},
+		{
+			name:      "typename is free",
+			query:     `{ __typename user(id: "1") { __typename id } }`,
+			wantDepth: 2,
+			wantCost:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := parseQuery(t, tt.query)
+			depth, cost := measure(doc.Operations[0].SelectionSet, tt.vars)
+			if depth != tt.wantDepth || cost != tt.wantCost {
+				t.Errorf("measure() = depth %d, cost %d; want depth %d, cost %d", depth, cost, tt.wantDepth, tt.wantCost)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.LimitsConfig
+		query    string
+		wantCode string // empty when the operation is accepted
+	}{
+		{
+			name:  "depth at the limit",
+			cfg:   config.LimitsConfig{MaxDepth: 4},
+			query: usersQuery,
+		},
+		{
+			name:     "depth over the limit",
+			cfg:      config.LimitsConfig{MaxDepth: 3},
+			query:    usersQuery,
+			wantCode: errDepthLimit,
+		},
+		{
+			name:  "complexity at the limit",
+			cfg:   config.LimitsConfig{MaxComplexity: usersQueryCost},
+			query: usersQuery,
+		},
+		{
+			name:     "complexity over the limit",
+			cfg:      config.LimitsConfig{MaxComplexity: usersQueryCost - 1},
+			query:    usersQuery,
+			wantCode: errComplexityLimit,
+		},
+		{
+			name:     "larger page over the limit",
+			cfg:      config.LimitsConfig{MaxComplexity: usersQueryCost},
+			query:    `{ users(first: 11) { edges { node { id } } } }`,
+			wantCode: errComplexityLimit,
+		},
+		{
+			name:  "introspection under tight limits",
+			cfg:   config.LimitsConfig{MaxDepth: 1, MaxComplexity: 1, Budget: 1, BudgetWindow: time.Hour},
+			query: introspectionQuery,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runLimits(t, newTestLimits(tt.cfg), "client", tt.query, nil)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("operation rejected: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("operation accepted, want %s", tt.wantCode)
+			}
+			if code := err.Extensions["code"]; code != tt.wantCode {
+				t.Errorf("extensions.code = %v, want %s", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestLimitsBudget(t *testing.T) {
+	l := newTestLimits(config.LimitsConfig{MaxComplexity: 100, Budget: 2 * usersQueryCost, BudgetWindow: time.Hour})
+
+	// Operations over the complexity limit are not charged
+	if err := runLimits(t, l, "a", `{ users(first: 50) { edges { node { id } } } }`, nil); err == nil {
+		t.Fatal("operation over the complexity limit was accepted")
+	}
+
+	for i := 1; i <= 2; i++ {
+		if err := runLimits(t, l, "a", usersQuery, nil); err != nil {
+			t.Fatalf("operation %d within the budget rejected: %v", i, err)
+		}
+	}
+
+	err := runLimits(t, l, "a", usersQuery, nil)
+	if err == nil {
+		t.Fatal("operation over the budget was accepted")
+	}
+	if code := err.Extensions["code"]; code != errBudgetExceeded {
+		t.Errorf("extensions.code = %v, want %s", code, errBudgetExceeded)
+	}
+	if remaining := err.Extensions["remaining"]; remaining != 0 {
+		t.Errorf("extensions.remaining = %v, want 0", remaining)
+	}
+	if retryAfter, _ := err.Extensions["retryAfter"].(int); retryAfter <= 0 || retryAfter > 3600 {
+		t.Errorf("extensions.retryAfter = %v, want the seconds until the window resets", err.Extensions["retryAfter"])
+	}
+
+	// Free operations still pass, and other clients have their own budget
+	if err := runLimits(t, l, "a", introspectionQuery, nil); err != nil {
+		t.Errorf("introspection rejected after the budget ran out: %v", err)
+	}
+	if err := runLimits(t, l, "b", usersQuery, nil); err != nil {
+		t.Errorf("operation of another client rejected: %v", err)
+	}
+}
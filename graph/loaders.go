@@ -0,0 +1,94 @@
+package graph
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
+	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"github.com/kitamersion/go-goservice/internal/domain/services"
+	"github.com/vikstrous/dataloadgen"
+)
+
+const (
+	// loaderWait is how long a loader collects keys before querying; resolvers
+	// of one selection set run concurrently, so a short wait is enough.
+	loaderWait = time.Millisecond
+	// maxUserBatch keeps the IN list of one query at a reasonable size.
+	maxUserBatch = 500
+)
+
+type loadersKey struct{}
+
+// Loaders batch and cache lookups for the lifetime of one request, so
+// resolving many users issues a single query.
+type Loaders struct {
+	User *dataloadgen.Loader[uuid.UUID, *entities.UserEntity]
+}
+
+func NewLoaders(userService *services.UserService) *Loaders {
+	return &Loaders{
+		User: dataloadgen.NewLoader(usersFetcher(userService),
+			dataloadgen.WithWait(loaderWait),
+			dataloadgen.WithBatchCapacity(maxUserBatch),
+		),
+	}
+}
+
+// LoaderMiddleware gives every request fresh loaders, so nothing is cached
+// across requests.
+func LoaderMiddleware(userService *services.UserService, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), loadersKey{}, NewLoaders(userService))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// loadersFor returns the loaders of the request, or nil outside of
+// LoaderMiddleware.
+func loadersFor(ctx context.Context) *Loaders {
+	loaders, _ := ctx.Value(loadersKey{}).(*Loaders)
+	return loaders
+}
+
+// usersFetcher answers a batch of IDs with one GetUsersByIDs call. IDs
+// without a user get the same not found error as GetUserByID.
+func usersFetcher(userService *services.UserService) func(ctx context.Context, ids []uuid.UUID) ([]*entities.UserEntity, []error) {
+	return func(ctx context.Context, ids []uuid.UUID) ([]*entities.UserEntity, []error) {
+		users := make([]*entities.UserEntity, len(ids))
+		errs := make([]error, len(ids))
+
+		byID, err := userService.GetUsersByIDs(ids)
+		for i, id := range ids {
+			switch user, ok := byID[id]; {
+			case err != nil:
+				errs[i] = err
+			case !ok:
+				errs[i] = apperrors.NotFound("user not found")
+			default:
+				users[i] = user
+			}
+		}
+		return users, errs
+	}
+}
+
+// loadUser goes through the request's loader when there is one.
+func (r *Resolver) loadUser(ctx context.Context, id uuid.UUID) (*entities.UserEntity, error) {
+	if loaders := loadersFor(ctx); loaders != nil {
+		return loaders.User.Load(ctx, id)
+	}
+	return r.UserService.GetUserByID(id)
+}
+
+// primeUsers caches users that were loaded some other way, e.g. by a listing,
+// for the rest of the request.
+func primeUsers(ctx context.Context, users []*entities.UserEntity) {
+	if loaders := loadersFor(ctx); loaders != nil {
+		for _, user := range users {
+			loaders.User.Prime(user.ID, user)
+		}
+	}
+}
@@ -2,19 +2,287 @@
 
 package model
 
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
 type CreateUserInput struct {
 	Name  string `json:"name"`
 	Email string `json:"email"`
+	// Makes the mutation safe to retry: repeating it with the same key and input returns the original user ID.
+	IdempotencyKey *string `json:"idempotencyKey,omitempty"`
+}
+
+// A domain event recorded in the outbox
+type Event struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	AggregateID string `json:"aggregateId"`
+	// Position of the event among the events of its aggregate
+	AggregateVersion int32 `json:"aggregateVersion"`
+	// The event message in protojson form
+	Payload   string    `json:"payload"`
+	CreatedAt time.Time `json:"createdAt"`
+	// When the event was published to Kafka; null while it is pending
+	PublishedAt *time.Time `json:"publishedAt,omitempty"`
+}
+
+type EventConnection struct {
+	Edges      []*EventEdge `json:"edges"`
+	PageInfo   *PageInfo    `json:"pageInfo"`
+	TotalCount int32        `json:"totalCount"`
+}
+
+type EventEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Event `json:"node"`
 }
 
 type Mutation struct {
 }
 
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor,omitempty"`
+	EndCursor       *string `json:"endCursor,omitempty"`
+}
+
 type Query struct {
 }
 
+type Subscription struct {
+}
+
+type UpdateUserInput struct {
+	ID    string  `json:"id"`
+	Name  *string `json:"name,omitempty"`
+	Email *string `json:"email,omitempty"`
+	// Fail with a CONFLICT error unless the user still has this version.
+	ExpectedVersion *int32 `json:"expectedVersion,omitempty"`
+}
+
 type User struct {
 	ID    string `json:"id"`
 	Name  string `json:"name"`
 	Email string `json:"email"`
+	// Bumped on every update; pass it as expectedVersion to guard against lost updates.
+	Version   int32     `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type UserConnection struct {
+	Edges      []*UserEdge `json:"edges"`
+	PageInfo   *PageInfo   `json:"pageInfo"`
+	TotalCount int32       `json:"totalCount"`
+}
+
+type UserEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *User  `json:"node"`
+}
+
+type UserEvent struct {
+	// Unique event ID, stable across redeliveries
+	ID     string        `json:"id"`
+	Type   UserEventType `json:"type"`
+	UserID string        `json:"userId"`
+	// Position of the event among the events of this user, when known
+	Sequence   *int32    `json:"sequence,omitempty"`
+	OccurredAt time.Time `json:"occurredAt"`
+	// Set on CREATED events
+	Name *string `json:"name,omitempty"`
+	// Set on CREATED events
+	Email *string `json:"email,omitempty"`
+}
+
+// All set fields must match. Cursors are only valid with the orderBy they were issued for.
+type UserFilter struct {
+	// Exact domain of the email address, e.g. example.com
+	EmailDomain *string `json:"emailDomain,omitempty"`
+	// Case-insensitive start of the name
+	NamePrefix *string `json:"namePrefix,omitempty"`
+	// Inclusive lower bound
+	CreatedAfter *time.Time `json:"createdAfter,omitempty"`
+	// Exclusive upper bound
+	CreatedBefore *time.Time `json:"createdBefore,omitempty"`
+	// Every word must occur in the name or email, case-insensitive
+	Search *string `json:"search,omitempty"`
+}
+
+type UserOrder struct {
+	Field     UserOrderField `json:"field"`
+	Direction OrderDirection `json:"direction"`
+}
+
+type OrderDirection string
+
+const (
+	OrderDirectionAsc  OrderDirection = "ASC"
+	OrderDirectionDesc OrderDirection = "DESC"
+)
+
+var AllOrderDirection = []OrderDirection{
+	OrderDirectionAsc,
+	OrderDirectionDesc,
+}
+
+func (e OrderDirection) IsValid() bool {
+	switch e {
+	case OrderDirectionAsc, OrderDirectionDesc:
+		return true
+	}
+	return false
+}
+
+func (e OrderDirection) String() string {
+	return string(e)
+}
+
+func (e *OrderDirection) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OrderDirection(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OrderDirection", str)
+	}
+	return nil
+}
+
+func (e OrderDirection) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *OrderDirection) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e OrderDirection) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type UserEventType string
+
+const (
+	UserEventTypeCreated UserEventType = "CREATED"
+	UserEventTypeUpdated UserEventType = "UPDATED"
+	UserEventTypeDeleted UserEventType = "DELETED"
+)
+
+var AllUserEventType = []UserEventType{
+	UserEventTypeCreated,
+	UserEventTypeUpdated,
+	UserEventTypeDeleted,
+}
+
+func (e UserEventType) IsValid() bool {
+	switch e {
+	case UserEventTypeCreated, UserEventTypeUpdated, UserEventTypeDeleted:
+		return true
+	}
+	return false
+}
+
+func (e UserEventType) String() string {
+	return string(e)
+}
+
+func (e *UserEventType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = UserEventType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid UserEventType", str)
+	}
+	return nil
+}
+
+func (e UserEventType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *UserEventType) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e UserEventType) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type UserOrderField string
+
+const (
+	UserOrderFieldCreatedAt UserOrderField = "CREATED_AT"
+	UserOrderFieldName      UserOrderField = "NAME"
+)
+
+var AllUserOrderField = []UserOrderField{
+	UserOrderFieldCreatedAt,
+	UserOrderFieldName,
+}
+
+func (e UserOrderField) IsValid() bool {
+	switch e {
+	case UserOrderFieldCreatedAt, UserOrderFieldName:
+		return true
+	}
+	return false
+}
+
+func (e UserOrderField) String() string {
+	return string(e)
+}
+
+func (e *UserOrderField) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = UserOrderField(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid UserOrderField", str)
+	}
+	return nil
+}
+
+func (e UserOrderField) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *UserOrderField) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e UserOrderField) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
 }
@@ -0,0 +1,103 @@
+package graph
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/sirupsen/logrus"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const errPersistedQueryNotAllowed = "PERSISTED_QUERY_NOT_ALLOWED"
+
+// PersistedQueries is an allowlist of operations loaded from a manifest that
+// maps the sha256 hash of each document to the document.
+//
+// It serves as the read-only cache of extension.AutomaticPersistedQuery, so
+// clients can send just the hash, and as an extension that rejects every
+// document not in the manifest. It must be added after the APQ extension.
+type PersistedQueries struct {
+	documents map[string]string
+	logger    *logrus.Logger
+}
+
+var _ interface {
+	graphql.Cache[string]
+	graphql.HandlerExtension
+	graphql.OperationParameterMutator
+} = &PersistedQueries{}
+
+// LoadPersistedQueries reads the manifest at path. Every hash must match its
+// document, so an edited or stale manifest is caught at startup.
+func LoadPersistedQueries(path string, logger *logrus.Logger) (*PersistedQueries, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read persisted query manifest %s: %w", path, err)
+	}
+
+	var manifest map[string]string
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse persisted query manifest %s: %w", path, err)
+	}
+
+	documents := make(map[string]string, len(manifest))
+	for hash, document := range manifest {
+		hash = strings.ToLower(hash)
+		if queryHash(document) != hash {
+			return nil, fmt.Errorf("persisted query manifest %s: hash %s does not match its document", path, hash)
+		}
+		documents[hash] = document
+	}
+	return &PersistedQueries{documents: documents, logger: logger}, nil
+}
+
+// Len returns the number of allowed operations.
+func (p *PersistedQueries) Len() int {
+	return len(p.documents)
+}
+
+// Get looks up the document of a hash sent by APQ clients.
+func (p *PersistedQueries) Get(_ context.Context, hash string) (string, bool) {
+	document, ok := p.documents[strings.ToLower(hash)]
+	return document, ok
+}
+
+// Add ignores documents sent along with their hash; only the manifest defines
+// what may run.
+func (p *PersistedQueries) Add(context.Context, string, string) {}
+
+func (p *PersistedQueries) ExtensionName() string {
+	return "PersistedQueryAllowlist"
+}
+
+func (p *PersistedQueries) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// MutateOperationParameters rejects documents that are not in the manifest.
+// Documents must match byte for byte, as they are compared by hash.
+func (p *PersistedQueries) MutateOperationParameters(_ context.Context, params *graphql.RawParams) *gqlerror.Error {
+	if params.Query == "" {
+		return nil // gqlgen rejects the missing operation itself
+	}
+	if _, ok := p.documents[queryHash(params.Query)]; ok {
+		return nil
+	}
+
+	p.logger.WithField("operation", params.OperationName).Warn("Rejected GraphQL operation missing from the persisted query manifest")
+	err := gqlerror.Errorf("operation is not in the persisted query allowlist")
+	errcode.Set(err, errPersistedQueryNotAllowed)
+	return err
+}
+
+func queryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,169 @@
+package graph
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	allowedQuery = `query Typename { __typename }`
+	adHocQuery   = `query Other { __typename }`
+)
+
+func writeManifest(t *testing.T, manifest map[string]string) string {
+	t.Helper()
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("failed to encode manifest: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "persisted-queries.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	return path
+}
+
+// newAllowlistServer serves the schema locked down to allowedQuery, wired as
+// cmd/graph does.
+func newAllowlistServer(t *testing.T) http.Handler {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	allowlist, err := LoadPersistedQueries(writeManifest(t, map[string]string{queryHash(allowedQuery): allowedQuery}), logger)
+	if err != nil {
+		t.Fatalf("LoadPersistedQueries() = %v", err)
+	}
+
+	srv := handler.New(NewExecutableSchema(Config{Resolvers: &Resolver{}}))
+	srv.AddTransport(transport.POST{})
+	srv.Use(extension.AutomaticPersistedQuery{Cache: allowlist})
+	srv.Use(allowlist)
+	return srv
+}
+
+type graphResponse struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+// postQuery sends query, and hash as an APQ extension when set.
+func postQuery(t *testing.T, srv http.Handler, query, hash string) graphResponse {
+	t.Helper()
+	body := map[string]any{}
+	if query != "" {
+		body["query"] = query
+	}
+	if hash != "" {
+		body["extensions"] = map[string]any{
+			"persistedQuery": map[string]any{"version": 1, "sha256Hash": hash},
+		}
+	}
+	data, _ := json.Marshal(body)
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(data)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	var res graphResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("invalid response %q: %v", w.Body, err)
+	}
+	return res
+}
+
+func TestPersistedQueries(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		hash     string
+		wantCode string // empty when the operation runs
+	}{
+		{
+			name:  "known document",
+			query: allowedQuery,
+		},
+		{
+			name: "known hash",
+			hash: queryHash(allowedQuery),
+		},
+		{
+			name: "known hash in upper case",
+			hash: strings.ToUpper(queryHash(allowedQuery)),
+		},
+		{
+			name:     "ad-hoc document",
+			query:    adHocQuery,
+			wantCode: errPersistedQueryNotAllowed,
+		},
+		{
+			name:     "unknown hash",
+			hash:     queryHash(adHocQuery),
+			wantCode: "PERSISTED_QUERY_NOT_FOUND",
+		},
+		{
+			name:     "ad-hoc document registered with its hash",
+			query:    adHocQuery,
+			hash:     queryHash(adHocQuery),
+			wantCode: errPersistedQueryNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := postQuery(t, newAllowlistServer(t), tt.query, tt.hash)
+			if tt.wantCode == "" {
+				if len(res.Errors) > 0 || res.Data["__typename"] != "Query" {
+					t.Fatalf("operation did not run: %+v", res)
+				}
+				return
+			}
+			if res.Data != nil || len(res.Errors) == 0 {
+				t.Fatalf("operation ran: %+v", res)
+			}
+			if code := res.Errors[0].Extensions["code"]; code != tt.wantCode {
+				t.Errorf("extensions.code = %v, want %s", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestPersistedQueriesIgnoreRegistration(t *testing.T) {
+	srv := newAllowlistServer(t)
+
+	// An APQ client registers a document by sending it with its hash; the
+	// allowlist must neither run it nor remember it for later hash-only requests
+	postQuery(t, srv, adHocQuery, queryHash(adHocQuery))
+	res := postQuery(t, srv, "", queryHash(adHocQuery))
+	if res.Data != nil || len(res.Errors) == 0 {
+		t.Fatalf("registered hash ran: %+v", res)
+	}
+	if code := res.Errors[0].Extensions["code"]; code != "PERSISTED_QUERY_NOT_FOUND" {
+		t.Errorf("extensions.code = %v, want PERSISTED_QUERY_NOT_FOUND", code)
+	}
+}
+
+func TestLoadPersistedQueriesChecksHashes(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	path := writeManifest(t, map[string]string{queryHash(allowedQuery): adHocQuery})
+	if _, err := LoadPersistedQueries(path, logger); err == nil {
+		t.Fatal("LoadPersistedQueries() accepted a hash that does not match its document")
+	}
+}
@@ -1,11 +1,25 @@
 package graph
 
-import "github.com/kitamersion/go-goservice/internal/domain/services"
+import (
+	"github.com/kitamersion/go-goservice/internal/domain/idempotency"
+	"github.com/kitamersion/go-goservice/internal/domain/services"
+	"github.com/kitamersion/go-goservice/internal/events/hub"
+)
 
 // This file will not be regenerated automatically.
 //
 // It serves as dependency injection for your app, add any dependencies you require here.
 
+const (
+	// maxPageSize caps the number of users returned by one connection page.
+	maxPageSize = 100
+	// createUserScope namespaces createUser idempotency keys from REST ones.
+	createUserScope = "graphql createUser"
+)
+
 type Resolver struct {
-	UserService *services.UserService
+	UserService  *services.UserService
+	EventService *services.EventService
+	Idempotency  *idempotency.Store
+	Events       *hub.Hub // feeds subscriptions
 }
@@ -7,44 +7,236 @@ package graph
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	"github.com/google/uuid"
 	"github.com/kitamersion/go-goservice/graph/model"
+	"github.com/kitamersion/go-goservice/internal/api/dto"
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
 	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"github.com/kitamersion/go-goservice/internal/domain/idempotency"
+	"github.com/kitamersion/go-goservice/internal/domain/services"
 )
 
 // CreateUser is the resolver for the createUser field.
 func (r *mutationResolver) CreateUser(ctx context.Context, input model.CreateUserInput) (string, error) {
-	entity := &entities.UserEntity{
+	req := dto.CreateUserRequest{
 		Name:  input.Name,
 		Email: input.Email,
 	}
+	req.Normalize()
+	if err := req.Validate(); err != nil {
+		return "", err
+	}
+
+	create := func(ctx context.Context) (string, error) {
+		user, err := r.UserService.CreateUser(ctx, &entities.UserEntity{
+			Name:  req.Name,
+			Email: req.Email,
+		})
+		if err != nil {
+			return "", err
+		}
+		return user.ID.String(), nil
+	}
+	if input.IdempotencyKey == nil {
+		return create(ctx)
+	}
 
-	id, err := r.UserService.CreateUser(ctx, entity)
+	// Only successful results are stored; failed attempts can be retried
+	key := *input.IdempotencyKey
+	if err := idempotency.ValidateKey(key); err != nil {
+		return "", err
+	}
+	hash := idempotency.Hash([]byte(createUserScope), []byte(req.Name), []byte(req.Email))
+	resp, _, err := r.Idempotency.Do(ctx, createUserScope, key, hash, func(ctx context.Context) (*idempotency.Response, error) {
+		id, err := create(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &idempotency.Response{StatusCode: http.StatusOK, Body: []byte(id)}, nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to create user: %w", err)
+		return "", err
 	}
-	return id.String(), nil
+	return string(resp.Body), nil
+}
+
+// UpdateUser is the resolver for the updateUser field.
+func (r *mutationResolver) UpdateUser(ctx context.Context, input model.UpdateUserInput) (*model.User, error) {
+	id, err := uuid.Parse(input.ID)
+	if err != nil {
+		return nil, apperrors.Validation("invalid UUID")
+	}
+
+	req := dto.PatchUserRequest{
+		Name:  input.Name,
+		Email: input.Email,
+	}
+	req.Normalize()
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	patch := services.UserPatch{
+		Name:             req.Name,
+		Email:            req.Email,
+		ExpectedVersions: expectedVersions(input.ExpectedVersion),
+	}
+
+	user, err := r.UserService.PatchUser(ctx, id, patch)
+	if err != nil {
+		return nil, err
+	}
+	return newUser(user), nil
+}
+
+// DeleteUser is the resolver for the deleteUser field.
+func (r *mutationResolver) DeleteUser(ctx context.Context, id string, expectedVersion *int32) (string, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return "", apperrors.Validation("invalid UUID")
+	}
+	if err := r.UserService.DeleteUser(ctx, uid, expectedVersions(expectedVersion)); err != nil {
+		return "", err
+	}
+	return uid.String(), nil
 }
 
 // User is the resolver for the user field.
 func (r *queryResolver) User(ctx context.Context, id string) (*model.User, error) {
 	uid, err := uuid.Parse(id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid UUID")
+		return nil, apperrors.Validation("invalid UUID")
+	}
+	user, err := r.loadUser(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	return newUser(user), nil
+}
+
+// Users is the resolver for the users field.
+func (r *queryResolver) Users(ctx context.Context, first *int32, after *string, filter *model.UserFilter, orderBy *model.UserOrder) (*model.UserConnection, error) {
+	limit := 20
+	if first != nil {
+		limit = int(*first)
+	}
+	if limit < 1 || limit > maxPageSize {
+		return nil, apperrors.Validation(fmt.Sprintf("first must be between 1 and %d", maxPageSize))
+	}
+
+	query, err := userQuery(filter, orderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor string
+	if after != nil {
+		cursor = *after
 	}
-	user, err := r.UserService.GetUserByID(uid)
+	page, err := r.UserService.ListUsersAfter(query, cursor, limit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user by ID")
+		return nil, err
 	}
-	if user == nil {
-		return nil, fmt.Errorf("user not found")
+	primeUsers(ctx, page.Users)
+
+	conn := &model.UserConnection{
+		Edges: make([]*model.UserEdge, 0, len(page.Users)),
+		PageInfo: &model.PageInfo{
+			HasNextPage:     page.NextCursor != "",
+			HasPreviousPage: cursor != "",
+		},
+		TotalCount: int32(page.Total),
 	}
-	return &model.User{
-		ID:    user.ID.String(),
-		Name:  user.Name,
-		Email: user.Email,
-	}, nil
+	for _, user := range page.Users {
+		conn.Edges = append(conn.Edges, &model.UserEdge{
+			Cursor: page.Cursor(user),
+			Node:   newUser(user),
+		})
+	}
+	if n := len(conn.Edges); n > 0 {
+		conn.PageInfo.StartCursor = &conn.Edges[0].Cursor
+		conn.PageInfo.EndCursor = &conn.Edges[n-1].Cursor
+	}
+	return conn, nil
+}
+
+// Events is the resolver for the events field.
+func (r *queryResolver) Events(ctx context.Context, first *int32, after *string, typeArg *string) (*model.EventConnection, error) {
+	limit := 20
+	if first != nil {
+		limit = int(*first)
+	}
+	if limit < 1 || limit > maxPageSize {
+		return nil, apperrors.Validation(fmt.Sprintf("first must be between 1 and %d", maxPageSize))
+	}
+
+	var cursor string
+	if after != nil {
+		cursor = *after
+	}
+	page, err := r.EventService.ListEventsAfter(deref(typeArg), cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &model.EventConnection{
+		Edges: make([]*model.EventEdge, 0, len(page.Events)),
+		PageInfo: &model.PageInfo{
+			HasNextPage:     page.NextCursor != "",
+			HasPreviousPage: cursor != "",
+		},
+		TotalCount: int32(page.Total),
+	}
+	for _, event := range page.Events {
+		conn.Edges = append(conn.Edges, &model.EventEdge{
+			Cursor: services.EventCursor(event),
+			Node:   newEvent(event),
+		})
+	}
+	if n := len(conn.Edges); n > 0 {
+		conn.PageInfo.StartCursor = &conn.Edges[0].Cursor
+		conn.PageInfo.EndCursor = &conn.Edges[n-1].Cursor
+	}
+	return conn, nil
+}
+
+// UserEvents is the resolver for the userEvents field.
+func (r *subscriptionResolver) UserEvents(ctx context.Context, types []model.UserEventType) (<-chan *model.UserEvent, error) {
+	if r.Events == nil {
+		return nil, apperrors.Unavailable("live user events are not available", nil)
+	}
+
+	eventTypes := make([]string, 0, len(types))
+	for _, t := range types {
+		eventTypes = append(eventTypes, hubEventTypes[t])
+	}
+	sub := r.Events.Subscribe(eventTypes)
+
+	// Ends when the client unsubscribes or disconnects (ctx), or when the hub
+	// ends the subscription
+	events := make(chan *model.UserEvent)
+	go func() {
+		defer close(events)
+		defer sub.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-sub.Events():
+				if !ok {
+					return
+				}
+				select {
+				case events <- newUserEvent(event):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
 }
 
 // Mutation returns MutationResolver implementation.
@@ -53,5 +245,9 @@ func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
@@ -0,0 +1,58 @@
+package dto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
+)
+
+// Request is a DTO that can clean up and check its own fields.
+type Request interface {
+	Normalize()
+	Validate() error
+}
+
+// Decode reads a JSON request body into req, rejecting unknown fields (such as
+// a client-supplied id or created_at), then normalizes and validates it.
+func Decode(body io.Reader, req Request) error {
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(req); err != nil {
+		return decodeError(err)
+	}
+	if decoder.More() {
+		return apperrors.Validation("request body must contain a single JSON object")
+	}
+
+	req.Normalize()
+	return req.Validate()
+}
+
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.Is(err, io.EOF):
+		return apperrors.Validation("request body is empty")
+	case errors.As(err, &typeErr):
+		return apperrors.InvalidFields([]apperrors.FieldError{{
+			Field:   typeErr.Field,
+			Message: fmt.Sprintf("must be a %s", typeErr.Type),
+		}})
+	case errors.As(err, &syntaxErr):
+		return apperrors.New(apperrors.KindValidation, "request body is not valid JSON", err)
+	}
+
+	// encoding/json reports unknown fields only through the message
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return apperrors.InvalidFields([]apperrors.FieldError{{
+			Field:   strings.Trim(field, `"`),
+			Message: "is not allowed",
+		}})
+	}
+	return apperrors.New(apperrors.KindValidation, "request body is not valid JSON", err)
+}
@@ -0,0 +1,90 @@
+package dto
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kitamersion/go-goservice/internal/domain/repositories"
+)
+
+const (
+	MaxSearchLength = 100
+	MaxSearchWords  = 5
+	MaxDomainLength = 253
+)
+
+// ListUsersQuery holds the filters and sort order of GET /users and the users
+// query. Field errors are named after the REST query parameters.
+type ListUsersQuery struct {
+	EmailDomain   string
+	NamePrefix    string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Search        string
+	Sort          string // "created_at", "name", or either prefixed with "-" for descending
+}
+
+func (q *ListUsersQuery) Normalize() {
+	q.EmailDomain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(q.EmailDomain)), "@")
+	q.NamePrefix = normalizeName(q.NamePrefix)
+	q.Search = normalizeName(q.Search)
+	q.Sort = strings.TrimSpace(q.Sort)
+}
+
+func (q *ListUsersQuery) Validate() error {
+	var v validator
+	if q.EmailDomain != "" && !isDomain(q.EmailDomain) {
+		v.add("email_domain", "must be a domain such as example.com")
+	}
+	if utf8.RuneCountInString(q.NamePrefix) > MaxNameLength {
+		v.add("name_prefix", fmt.Sprintf("must be at most %d characters", MaxNameLength))
+	}
+	switch {
+	case utf8.RuneCountInString(q.Search) > MaxSearchLength:
+		v.add("q", fmt.Sprintf("must be at most %d characters", MaxSearchLength))
+	case len(strings.Fields(q.Search)) > MaxSearchWords:
+		v.add("q", fmt.Sprintf("must have at most %d words", MaxSearchWords))
+	}
+	if q.CreatedAfter != nil && q.CreatedBefore != nil && !q.CreatedBefore.After(*q.CreatedAfter) {
+		v.add("created_before", "must be later than created_after")
+	}
+	if q.Sort != "" {
+		if _, ok := repositories.ParseUserSort(q.Sort); !ok {
+			v.add("sort", "must be one of created_at, -created_at, name, -name")
+		}
+	}
+	return v.err()
+}
+
+// UserQuery converts a validated query into the repository's filter spec.
+func (q *ListUsersQuery) UserQuery() repositories.UserQuery {
+	sort, _ := repositories.ParseUserSort(q.Sort)
+	return repositories.UserQuery{
+		Filter: repositories.UserFilter{
+			EmailDomain:   q.EmailDomain,
+			NamePrefix:    q.NamePrefix,
+			CreatedAfter:  q.CreatedAfter,
+			CreatedBefore: q.CreatedBefore,
+			Search:        q.Search,
+		},
+		Sort: sort,
+	}
+}
+
+func isDomain(domain string) bool {
+	if len(domain) > MaxDomainLength || strings.ContainsAny(domain, "@ \t") {
+		return false
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if label == "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kitamersion/go-goservice/internal/domain/entities"
+)
+
+// UserResponse is the representation of a user returned by the REST API.
+type UserResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int64     `json:"version"`
+}
+
+func NewUserResponse(user *entities.UserEntity) UserResponse {
+	return UserResponse{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		Version:   user.Version,
+	}
+}
+
+// PageInfo describes either an offset page (offset set) or a cursor page
+// (next_cursor set unless it is the last page).
+type PageInfo struct {
+	Limit      int    `json:"limit"`
+	Offset     *int   `json:"offset,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int64  `json:"total"`
+}
+
+type ListUsersResponse struct {
+	Data       []UserResponse `json:"data"`
+	Pagination PageInfo       `json:"pagination"`
+}
+
+func NewListUsersResponse(users []*entities.UserEntity, page PageInfo) ListUsersResponse {
+	data := make([]UserResponse, 0, len(users))
+	for _, user := range users {
+		data = append(data, NewUserResponse(user))
+	}
+	return ListUsersResponse{
+		Data:       data,
+		Pagination: page,
+	}
+}
+
+// EventResponse is a recorded domain event. Payload is the event message in
+// protojson form.
+type EventResponse struct {
+	ID               uuid.UUID       `json:"id"`
+	Type             string          `json:"type"`
+	AggregateID      string          `json:"aggregate_id"`
+	AggregateVersion int64           `json:"aggregate_version"`
+	Payload          json.RawMessage `json:"payload"`
+	CreatedAt        time.Time       `json:"created_at"`
+	PublishedAt      *time.Time      `json:"published_at,omitempty"`
+}
+
+func NewEventResponse(event *entities.Event) EventResponse {
+	return EventResponse{
+		ID:               event.ID,
+		Type:             event.Type,
+		AggregateID:      event.AggregateID,
+		AggregateVersion: event.AggregateVersion,
+		Payload:          json.RawMessage(event.Payload),
+		CreatedAt:        event.CreatedAt,
+		PublishedAt:      event.PublishedAt,
+	}
+}
+
+type ListEventsResponse struct {
+	Data       []EventResponse `json:"data"`
+	Pagination PageInfo        `json:"pagination"`
+}
+
+func NewListEventsResponse(events []*entities.Event, page PageInfo) ListEventsResponse {
+	data := make([]EventResponse, 0, len(events))
+	for _, event := range events {
+		data = append(data, NewEventResponse(event))
+	}
+	return ListEventsResponse{
+		Data:       data,
+		Pagination: page,
+	}
+}
+
+// ImportJobResponse reports the progress of a bulk user import.
+type ImportJobResponse struct {
+	ID         uuid.UUID             `json:"id"`
+	Format     string                `json:"format"`
+	Status     string                `json:"status"`
+	Processed  int                   `json:"processed"`
+	Succeeded  int                   `json:"succeeded"`
+	Failed     int                   `json:"failed"`
+	RowErrors  []ImportRowErrorEntry `json:"row_errors"`
+	Error      string                `json:"error,omitempty"`
+	CreatedAt  time.Time             `json:"created_at"`
+	UpdatedAt  time.Time             `json:"updated_at"`
+	FinishedAt *time.Time            `json:"finished_at,omitempty"`
+}
+
+// ImportRowErrorEntry explains why one input row was not imported. Rows are
+// numbered from 1; the CSV header line is not counted.
+type ImportRowErrorEntry struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func NewImportJobResponse(job *entities.ImportJob) ImportJobResponse {
+	rowErrors := make([]ImportRowErrorEntry, 0, len(job.RowErrors))
+	for _, rowErr := range job.RowErrors {
+		rowErrors = append(rowErrors, ImportRowErrorEntry(rowErr))
+	}
+	return ImportJobResponse{
+		ID:         job.ID,
+		Format:     job.Format,
+		Status:     job.Status,
+		Processed:  job.Processed,
+		Succeeded:  job.Succeeded,
+		Failed:     job.Failed,
+		RowErrors:  rowErrors,
+		Error:      job.Error,
+		CreatedAt:  job.CreatedAt,
+		UpdatedAt:  job.UpdatedAt,
+		FinishedAt: job.FinishedAt,
+	}
+}
@@ -0,0 +1,136 @@
+// Package dto holds the request types accepted by the REST handlers and the
+// GraphQL resolvers, together with the validation rules they share.
+package dto
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
+)
+
+const (
+	MaxNameLength  = 100
+	MaxEmailLength = 254 // RFC 5321 path limit
+)
+
+// CreateUserRequest is the body of POST /users and the createUser input.
+type CreateUserRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func (r *CreateUserRequest) Normalize() {
+	r.Name = normalizeName(r.Name)
+	r.Email = normalizeEmail(r.Email)
+}
+
+func (r *CreateUserRequest) Validate() error {
+	var v validator
+	v.name("name", r.Name)
+	v.email("email", r.Email)
+	return v.err()
+}
+
+// ReplaceUserRequest is the body of PUT /users/:id; every field is required.
+type ReplaceUserRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func (r *ReplaceUserRequest) Normalize() {
+	r.Name = normalizeName(r.Name)
+	r.Email = normalizeEmail(r.Email)
+}
+
+func (r *ReplaceUserRequest) Validate() error {
+	var v validator
+	v.name("name", r.Name)
+	v.email("email", r.Email)
+	return v.err()
+}
+
+// PatchUserRequest is the body of PATCH /users/:id; absent fields are left
+// unchanged, but fields that are present follow the same rules as on create.
+type PatchUserRequest struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email"`
+}
+
+func (r *PatchUserRequest) Normalize() {
+	if r.Name != nil {
+		name := normalizeName(*r.Name)
+		r.Name = &name
+	}
+	if r.Email != nil {
+		email := normalizeEmail(*r.Email)
+		r.Email = &email
+	}
+}
+
+func (r *PatchUserRequest) Validate() error {
+	var v validator
+	if r.Name != nil {
+		v.name("name", *r.Name)
+	}
+	if r.Email != nil {
+		v.email("email", *r.Email)
+	}
+	return v.err()
+}
+
+func normalizeName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// validator collects field errors so clients see every problem at once.
+type validator struct {
+	fields []apperrors.FieldError
+}
+
+func (v *validator) add(field, message string) {
+	v.fields = append(v.fields, apperrors.FieldError{Field: field, Message: message})
+}
+
+func (v *validator) name(field, name string) {
+	switch {
+	case name == "":
+		v.add(field, "is required")
+	case utf8.RuneCountInString(name) > MaxNameLength:
+		v.add(field, fmt.Sprintf("must be at most %d characters", MaxNameLength))
+	}
+}
+
+func (v *validator) email(field, email string) {
+	if email == "" {
+		v.add(field, "is required")
+		return
+	}
+	if len(email) > MaxEmailLength {
+		v.add(field, fmt.Sprintf("must be at most %d characters", MaxEmailLength))
+		return
+	}
+
+	// Only accept a bare address: no display name, comments or angle brackets
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || addr.Address != email {
+		v.add(field, "must be a valid email address")
+		return
+	}
+	if at := strings.LastIndexByte(email, '@'); !strings.Contains(email[at+1:], ".") {
+		v.add(field, "must have a fully qualified domain")
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return apperrors.InvalidFields(v.fields)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kitamersion/go-goservice/internal/api/problem"
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
+	"github.com/kitamersion/go-goservice/internal/domain/repositories"
+)
+
+// Users are tagged with their version as a strong entity tag, e.g. "3".
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatchVersions parses the If-Match header, a list of entity tags such as
+// "3", "4". ok is false when the header is absent or "*", in which case the
+// update is unconditional. Otherwise the update only applies to one of the
+// returned versions. If-Match uses the strong comparison, under which a weak
+// tag such as W/"3" never matches, so weak tags are left out; a header of only
+// weak tags yields no versions and always fails.
+func ifMatchVersions(c *gin.Context) (versions []int64, ok bool, err error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return nil, false, nil
+	}
+
+	versions = []int64{}
+	for _, tag := range entityTags(header) {
+		tag, weak := strings.CutPrefix(tag, "W/")
+		version, err := parseETag(tag)
+		if err != nil {
+			return nil, false, err
+		}
+		if !weak {
+			versions = append(versions, version)
+		}
+	}
+	return versions, true, nil
+}
+
+// ifNoneMatch reports whether the If-None-Match header lists the version. It
+// uses the weak comparison, so W/"3" matches "3", and "*" matches any version.
+// Tags that are not ours cannot match and are ignored.
+func ifNoneMatch(c *gin.Context, version int64) bool {
+	header := strings.TrimSpace(c.GetHeader("If-None-Match"))
+	if header == "*" {
+		return true
+	}
+	for _, tag := range entityTags(header) {
+		tag = strings.TrimPrefix(tag, "W/")
+		if v, err := parseETag(tag); err == nil && v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// entityTags splits a list of entity tags, skipping empty elements. Our tags
+// never contain commas, so splitting on them is enough.
+func entityTags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parseETag reads the version from an opaque tag such as "3".
+func parseETag(tag string) (int64, error) {
+	tag, quoted := strings.CutPrefix(tag, `"`)
+	tag, closed := strings.CutSuffix(tag, `"`)
+	if !quoted || !closed {
+		return 0, apperrors.Validation(`If-Match must be a list of entity tags such as "3"`)
+	}
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil {
+		return 0, apperrors.Validation(`If-Match must be a list of entity tags such as "3"`)
+	}
+	return version, nil
+}
+
+// writeUpdateError reports a stale version as 412 when the client asked for a
+// conditional write, and as a plain conflict otherwise.
+func writeUpdateError(c *gin.Context, err error, conditional bool) {
+	if conditional && errors.Is(err, repositories.ErrVersionConflict) {
+		problem.Write(c, http.StatusPreconditionFailed, apperrors.KindConflict, "If-Match does not match the current version of the user")
+		return
+	}
+	problem.Error(c, err)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kitamersion/go-goservice/internal/api/dto"
+	"github.com/kitamersion/go-goservice/internal/api/problem"
+	"github.com/kitamersion/go-goservice/internal/domain/services"
+)
+
+type EventHandler struct {
+	eventService *services.EventService
+}
+
+func NewEventHandler(eventService *services.EventService) *EventHandler {
+	return &EventHandler{
+		eventService: eventService,
+	}
+}
+
+// ListEvents pages through the recorded events, oldest first, with the same
+// opaque cursor (?cursor=) as ListUsers. ?type= keeps the events of one type.
+func (h *EventHandler) ListEvents(c *gin.Context) {
+	limit, err := queryInt(c, "limit", defaultPageLimit)
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		problem.BadRequest(c, "limit must be between 1 and "+strconv.Itoa(maxPageLimit))
+		return
+	}
+
+	page, err := h.eventService.ListEventsAfter(c.Query("type"), c.Query("cursor"), limit)
+	if err != nil {
+		problem.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewListEventsResponse(page.Events, dto.PageInfo{
+		Limit:      limit,
+		NextCursor: page.NextCursor,
+		Total:      page.Total,
+	}))
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/kitamersion/go-goservice/internal/api/dto"
+	"github.com/kitamersion/go-goservice/internal/api/problem"
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
+	"github.com/kitamersion/go-goservice/internal/imports"
+)
+
+type ImportHandler struct {
+	importer     *imports.Importer
+	maxBodyBytes int64
+}
+
+func NewImportHandler(importer *imports.Importer, maxBodyBytes int64) *ImportHandler {
+	return &ImportHandler{
+		importer:     importer,
+		maxBodyBytes: maxBodyBytes,
+	}
+}
+
+// ImportUsers accepts an NDJSON or CSV upload and answers 202 with the job to
+// poll. The body is spooled to disk first, so a dropped connection never
+// leaves a half-imported job behind.
+func (h *ImportHandler) ImportUsers(c *gin.Context) {
+	format, err := imports.FormatFromContentType(c.ContentType())
+	if err != nil {
+		problem.Write(c, http.StatusUnsupportedMediaType, apperrors.KindValidation, apperrors.Message(err, "unsupported Content-Type"))
+		return
+	}
+
+	file, err := os.CreateTemp("", "user-import-*")
+	if err != nil {
+		problem.Error(c, err)
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, h.maxBodyBytes)
+	if _, err := io.Copy(file, body); err != nil {
+		discard(file)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			problem.Write(c, http.StatusRequestEntityTooLarge, apperrors.KindValidation, "import body is too large")
+			return
+		}
+		problem.BadRequest(c, "failed to read import body")
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		discard(file)
+		problem.Error(c, err)
+		return
+	}
+
+	job, err := h.importer.Enqueue(format, file)
+	if err != nil {
+		discard(file)
+		problem.Error(c, err)
+		return
+	}
+
+	c.Header("Location", "/api/v1/jobs/"+job.ID.String())
+	c.JSON(http.StatusAccepted, dto.NewImportJobResponse(job))
+}
+
+func (h *ImportHandler) GetJob(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		problem.BadRequest(c, "Invalid UUID")
+		return
+	}
+
+	job, err := h.importer.Job(id)
+	if err != nil {
+		problem.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewImportJobResponse(job))
+}
+
+// CustomMethod serves a Google-style custom method such as /users:import.
+// Gin has no way to register a literal colon, so the route is registered as
+// "/users:import", which Gin treats as the parameter "import" and also
+// matches paths like /usersfoo; only the exact path is let through.
+func CustomMethod(verb string, handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Param(verb) != ":"+verb {
+			problem.Write(c, http.StatusNotFound, apperrors.KindNotFound, "route not found")
+			return
+		}
+		handler(c)
+	}
+}
+
+func discard(file *os.File) {
+	file.Close()
+	os.Remove(file.Name())
+}
@@ -2,13 +2,24 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/kitamersion/go-goservice/internal/api/dto"
+	"github.com/kitamersion/go-goservice/internal/api/problem"
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
 	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"github.com/kitamersion/go-goservice/internal/domain/repositories"
 	"github.com/kitamersion/go-goservice/internal/domain/services"
 )
 
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
 type UserHandler struct {
 	userService *services.UserService
 }
@@ -20,33 +31,236 @@ func NewUserHandler(userService *services.UserService) *UserHandler {
 }
 
 func (h *UserHandler) CreateUser(c *gin.Context) {
-	var user entities.UserEntity
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req dto.CreateUserRequest
+	if err := dto.Decode(c.Request.Body, &req); err != nil {
+		problem.Error(c, err)
 		return
 	}
 
-	if _, err := h.userService.CreateUser(c.Request.Context(), &user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	user, err := h.userService.CreateUser(c.Request.Context(), &entities.UserEntity{
+		Name:  req.Name,
+		Email: req.Email,
+	})
+	if err != nil {
+		problem.Error(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, user)
+	c.Header("ETag", etag(user.Version))
+	c.JSON(http.StatusCreated, dto.NewUserResponse(user))
 }
 
 func (h *UserHandler) GetUser(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID"})
+		problem.BadRequest(c, "Invalid UUID")
 		return
 	}
 
 	user, err := h.userService.GetUserByID(id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		problem.Error(c, err)
+		return
+	}
+
+	tag := etag(user.Version)
+	c.Header("ETag", tag)
+	if ifNoneMatch(c, user.Version) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewUserResponse(user))
+}
+
+// ListUsers pages with an opaque cursor (?cursor=); passing ?offset= switches
+// to the older offset paging, kept for backwards compatibility. Both modes
+// accept the filters and sort order of listUsersQuery.
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	limit, err := queryInt(c, "limit", defaultPageLimit)
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		problem.BadRequest(c, "limit must be between 1 and "+strconv.Itoa(maxPageLimit))
+		return
+	}
+
+	query, err := listUsersQuery(c)
+	if err != nil {
+		problem.Error(c, err)
+		return
+	}
+
+	if _, useOffset := c.GetQuery("offset"); useOffset {
+		h.listUsersByOffset(c, query, limit)
+		return
+	}
+
+	page, err := h.userService.ListUsersAfter(query, c.Query("cursor"), limit)
+	if err != nil {
+		problem.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewListUsersResponse(page.Users, dto.PageInfo{
+		Limit:      limit,
+		NextCursor: page.NextCursor,
+		Total:      page.Total,
+	}))
+}
+
+func (h *UserHandler) listUsersByOffset(c *gin.Context, query repositories.UserQuery, limit int) {
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil || offset < 0 {
+		problem.BadRequest(c, "offset must be a non-negative integer")
+		return
+	}
+
+	users, total, err := h.userService.ListUsers(query, limit, offset)
+	if err != nil {
+		problem.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewListUsersResponse(users, dto.PageInfo{
+		Limit:  limit,
+		Offset: &offset,
+		Total:  total,
+	}))
+}
+
+// ReplaceUser handles PUT: every writable field is replaced. With If-Match the
+// update only applies to that version of the user.
+func (h *UserHandler) ReplaceUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		problem.BadRequest(c, "Invalid UUID")
+		return
+	}
+
+	expected, conditional, err := ifMatchVersions(c)
+	if err != nil {
+		problem.Error(c, err)
+		return
+	}
+
+	var req dto.ReplaceUserRequest
+	if err := dto.Decode(c.Request.Body, &req); err != nil {
+		problem.Error(c, err)
+		return
+	}
+
+	user, err := h.userService.ReplaceUser(c.Request.Context(), id, req.Name, req.Email, expected)
+	if err != nil {
+		writeUpdateError(c, err, conditional)
+		return
+	}
+
+	c.Header("ETag", etag(user.Version))
+	c.JSON(http.StatusOK, dto.NewUserResponse(user))
+}
+
+// PatchUser handles PATCH: only the fields present in the body are changed.
+// With If-Match the update only applies to that version of the user.
+func (h *UserHandler) PatchUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		problem.BadRequest(c, "Invalid UUID")
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	expected, conditional, err := ifMatchVersions(c)
+	if err != nil {
+		problem.Error(c, err)
+		return
+	}
+
+	var req dto.PatchUserRequest
+	if err := dto.Decode(c.Request.Body, &req); err != nil {
+		problem.Error(c, err)
+		return
+	}
+
+	patch := services.UserPatch{
+		Name:             req.Name,
+		Email:            req.Email,
+		ExpectedVersions: expected,
+	}
+	user, err := h.userService.PatchUser(c.Request.Context(), id, patch)
+	if err != nil {
+		writeUpdateError(c, err, conditional)
+		return
+	}
+
+	c.Header("ETag", etag(user.Version))
+	c.JSON(http.StatusOK, dto.NewUserResponse(user))
+}
+
+// DeleteUser handles DELETE. With If-Match the user is only deleted at that
+// version.
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		problem.BadRequest(c, "Invalid UUID")
+		return
+	}
+
+	expected, conditional, err := ifMatchVersions(c)
+	if err != nil {
+		problem.Error(c, err)
+		return
+	}
+
+	if err := h.userService.DeleteUser(c.Request.Context(), id, expected); err != nil {
+		writeUpdateError(c, err, conditional)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// listUsersQuery reads the filter and sort query parameters of GET /users.
+func listUsersQuery(c *gin.Context) (repositories.UserQuery, error) {
+	query := dto.ListUsersQuery{
+		EmailDomain: c.Query("email_domain"),
+		NamePrefix:  c.Query("name_prefix"),
+		Search:      c.Query("q"),
+		Sort:        c.Query("sort"),
+	}
+
+	var fields []apperrors.FieldError
+	for _, param := range []struct {
+		key    string
+		target **time.Time
+	}{
+		{"created_after", &query.CreatedAfter},
+		{"created_before", &query.CreatedBefore},
+	} {
+		value := c.Query(param.key)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			fields = append(fields, apperrors.FieldError{Field: param.key, Message: "must be an RFC 3339 timestamp"})
+			continue
+		}
+		*param.target = &t
+	}
+	if len(fields) > 0 {
+		return repositories.UserQuery{}, apperrors.InvalidFields(fields)
+	}
+
+	query.Normalize()
+	if err := query.Validate(); err != nil {
+		return repositories.UserQuery{}, err
+	}
+	return query.UserQuery(), nil
+}
+
+func queryInt(c *gin.Context, key string, fallback int) (int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
 }
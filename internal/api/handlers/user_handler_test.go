@@ -0,0 +1,495 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/kitamersion/go-goservice/internal/api/problem"
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
+	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"github.com/kitamersion/go-goservice/internal/domain/pagination"
+	"github.com/kitamersion/go-goservice/internal/domain/repositories"
+	"github.com/kitamersion/go-goservice/internal/domain/services"
+)
+
+// fakeUsers keeps users in memory. Methods the handlers under test do not
+// reach panic through the embedded nil interface.
+type fakeUsers struct {
+	repositories.UserRepository
+	users  map[uuid.UUID]entities.UserEntity
+	query  repositories.UserQuery // of the last list call
+	events *fakeEvents            // written in the same transactions
+}
+
+func (f *fakeUsers) Create(user *entities.UserEntity) error {
+	f.users[user.ID] = *user
+	return nil
+}
+
+func (f *fakeUsers) GetByID(id uuid.UUID) (*entities.UserEntity, error) {
+	user, ok := f.users[id]
+	if !ok {
+		return nil, apperrors.NotFound("user not found")
+	}
+	return &user, nil
+}
+
+func (f *fakeUsers) GetByIDForUpdate(id uuid.UUID) (*entities.UserEntity, error) {
+	return f.GetByID(id)
+}
+
+func (f *fakeUsers) Update(user *entities.UserEntity) error {
+	stored, ok := f.users[user.ID]
+	if !ok {
+		return apperrors.NotFound("user not found")
+	}
+	if stored.Version != user.Version {
+		return apperrors.New(apperrors.KindConflict, "user was modified by another request", repositories.ErrVersionConflict)
+	}
+	user.Version++
+	f.users[user.ID] = *user
+	return nil
+}
+
+func (f *fakeUsers) Delete(id uuid.UUID) error {
+	if _, ok := f.users[id]; !ok {
+		return apperrors.NotFound("user not found")
+	}
+	delete(f.users, id)
+	return nil
+}
+
+func (f *fakeUsers) ListAfter(query repositories.UserQuery, _ *pagination.Cursor, _ int) ([]*entities.UserEntity, error) {
+	f.query = query
+	return nil, nil
+}
+
+func (f *fakeUsers) Count(repositories.UserFilter) (int64, error) {
+	return 0, nil
+}
+
+type fakeEvents struct {
+	repositories.EventRepository
+	events []*entities.Event
+}
+
+func (f *fakeEvents) NextVersion(string) (int64, error) {
+	return int64(len(f.events) + 1), nil
+}
+
+func (f *fakeEvents) Create(event *entities.Event) error {
+	f.events = append(f.events, event)
+	return nil
+}
+
+type fakeTransactor struct {
+	repos repositories.Repositories
+}
+
+func (f *fakeTransactor) WithinTransaction(_ context.Context, fn func(repos repositories.Repositories) error) error {
+	return fn(f.repos)
+}
+
+// newTestRouter serves the user handlers on top of in-memory repositories
+// holding a single user at version 3.
+func newTestRouter(t *testing.T) (*gin.Engine, *fakeUsers, uuid.UUID) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	id := uuid.New()
+	users := &fakeUsers{users: map[uuid.UUID]entities.UserEntity{
+		id: {ID: id, Name: "Jane Smith", Email: "jane@example.com", Version: 3, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+	}}
+	users.events = &fakeEvents{}
+	transactor := &fakeTransactor{repos: repositories.Repositories{Users: users, Events: users.events}}
+	handler := NewUserHandler(services.NewUserService(users, transactor))
+
+	r := gin.New()
+	r.GET("/users", handler.ListUsers)
+	r.POST("/users", handler.CreateUser)
+	r.GET("/users/:id", handler.GetUser)
+	r.PUT("/users/:id", handler.ReplaceUser)
+	r.PATCH("/users/:id", handler.PatchUser)
+	r.DELETE("/users/:id", handler.DeleteUser)
+	return r, users, id
+}
+
+func serve(r http.Handler, method, path, ifMatch, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) problem.Details {
+	t.Helper()
+	if got := w.Header().Get("Content-Type"); got != problem.ContentType {
+		t.Fatalf("Content-Type = %q, want %q", got, problem.ContentType)
+	}
+	var details problem.Details
+	if err := json.Unmarshal(w.Body.Bytes(), &details); err != nil {
+		t.Fatalf("failed to decode problem: %v", err)
+	}
+	return details
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifMatch     string
+		wantStatus  int
+		wantVersion int64 // stored version afterwards
+	}{
+		{name: "absent", wantStatus: http.StatusOK, wantVersion: 4},
+		{name: "any", ifMatch: "*", wantStatus: http.StatusOK, wantVersion: 4},
+		{name: "strong current", ifMatch: `"3"`, wantStatus: http.StatusOK, wantVersion: 4},
+		{name: "strong with spaces", ifMatch: ` "3" `, wantStatus: http.StatusOK, wantVersion: 4},
+		{name: "strong stale", ifMatch: `"2"`, wantStatus: http.StatusPreconditionFailed, wantVersion: 3},
+		{name: "weak current", ifMatch: `W/"3"`, wantStatus: http.StatusPreconditionFailed, wantVersion: 3},
+		{name: "list with current", ifMatch: `"2", "3"`, wantStatus: http.StatusOK, wantVersion: 4},
+		{name: "list with weak current", ifMatch: `W/"3", "3"`, wantStatus: http.StatusOK, wantVersion: 4},
+		{name: "list stale", ifMatch: `"1", W/"3", "2"`, wantStatus: http.StatusPreconditionFailed, wantVersion: 3},
+		{name: "list malformed", ifMatch: `"3", 4`, wantStatus: http.StatusBadRequest, wantVersion: 3},
+		{name: "weak malformed", ifMatch: `W/3`, wantStatus: http.StatusBadRequest, wantVersion: 3},
+		{name: "unquoted", ifMatch: `3`, wantStatus: http.StatusBadRequest, wantVersion: 3},
+		{name: "unterminated", ifMatch: `"3`, wantStatus: http.StatusBadRequest, wantVersion: 3},
+		{name: "not a version", ifMatch: `"abc"`, wantStatus: http.StatusBadRequest, wantVersion: 3},
+	}
+
+	for _, method := range []string{http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		for _, tt := range tests {
+			t.Run(method+" "+tt.name, func(t *testing.T) {
+				r, users, id := newTestRouter(t)
+
+				wantStatus := tt.wantStatus
+				if method == http.MethodDelete && wantStatus == http.StatusOK {
+					wantStatus = http.StatusNoContent
+				}
+				w := serve(r, method, "/users/"+id.String(), tt.ifMatch, `{"name":"New Name","email":"jane@example.com"}`)
+				if w.Code != wantStatus {
+					t.Fatalf("status = %d, want %d: %s", w.Code, wantStatus, w.Body)
+				}
+				stored, exists := users.users[id]
+				if method == http.MethodDelete {
+					if exists != (wantStatus != http.StatusNoContent) {
+						t.Errorf("user exists = %v after %d", exists, w.Code)
+					}
+				} else if stored.Version != tt.wantVersion {
+					t.Errorf("stored version = %d, want %d", stored.Version, tt.wantVersion)
+				}
+
+				switch wantStatus {
+				case http.StatusOK:
+					if got, want := w.Header().Get("ETag"), etag(tt.wantVersion); got != want {
+						t.Errorf("ETag = %s, want %s", got, want)
+					}
+				case http.StatusPreconditionFailed:
+					if details := decodeProblem(t, w); details.Code != string(apperrors.KindConflict) {
+						t.Errorf("code = %s, want %s", details.Code, apperrors.KindConflict)
+					}
+				case http.StatusBadRequest:
+					if details := decodeProblem(t, w); details.Code != string(apperrors.KindValidation) {
+						t.Errorf("code = %s, want %s", details.Code, apperrors.KindValidation)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestIfNoneMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{name: "absent", wantStatus: http.StatusOK},
+		{name: "any", ifNoneMatch: "*", wantStatus: http.StatusNotModified},
+		{name: "strong current", ifNoneMatch: `"3"`, wantStatus: http.StatusNotModified},
+		{name: "weak current", ifNoneMatch: `W/"3"`, wantStatus: http.StatusNotModified},
+		{name: "list with current", ifNoneMatch: `"2", W/"3"`, wantStatus: http.StatusNotModified},
+		{name: "stale", ifNoneMatch: `"2"`, wantStatus: http.StatusOK},
+		{name: "list stale", ifNoneMatch: `"1", W/"2"`, wantStatus: http.StatusOK},
+		{name: "not ours", ifNoneMatch: `"abc", 3`, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _, id := newTestRouter(t)
+
+			req := httptest.NewRequest(http.MethodGet, "/users/"+id.String(), nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got, want := w.Header().Get("ETag"), etag(3); got != want {
+				t.Errorf("ETag = %s, want %s", got, want)
+			}
+			if tt.wantStatus == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("304 has a body: %s", w.Body)
+			}
+		})
+	}
+}
+
+func TestUnchangedUpdate(t *testing.T) {
+	for _, method := range []string{http.MethodPut, http.MethodPatch} {
+		t.Run(method, func(t *testing.T) {
+			r, users, id := newTestRouter(t)
+			events := users.events
+
+			w := serve(r, method, "/users/"+id.String(), `"3"`, `{"name":"Jane Smith","email":"JANE@example.com"}`)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+			}
+			if got := users.users[id].Version; got != 3 {
+				t.Errorf("stored version = %d, want 3", got)
+			}
+			if got, want := w.Header().Get("ETag"), etag(3); got != want {
+				t.Errorf("ETag = %s, want %s", got, want)
+			}
+			if len(events.events) != 0 {
+				t.Errorf("recorded %d events for an unchanged user", len(events.events))
+			}
+		})
+	}
+}
+
+func TestUserRequestValidation(t *testing.T) {
+	longName := strings.Repeat("a", 101)
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		wantFields []apperrors.FieldError // for 400s, in order
+		wantName   string                 // stored afterwards, for successes
+		wantEmail  string
+	}{
+		{
+			name:       "create normalizes name and email",
+			method:     http.MethodPost,
+			body:       `{"name":"  New \t  Name ","email":" New@Example.COM "}`,
+			wantStatus: http.StatusCreated,
+			wantName:   "New Name",
+			wantEmail:  "new@example.com",
+		},
+		{
+			name:       "create without fields",
+			method:     http.MethodPost,
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+			wantFields: []apperrors.FieldError{{Field: "name", Message: "is required"}, {Field: "email", Message: "is required"}},
+		},
+		{
+			name:       "create with a blank name",
+			method:     http.MethodPost,
+			body:       `{"name":"   ","email":"new@example.com"}`,
+			wantStatus: http.StatusBadRequest,
+			wantFields: []apperrors.FieldError{{Field: "name", Message: "is required"}},
+		},
+		{
+			name:       "create with a long name",
+			method:     http.MethodPost,
+			body:       `{"name":"` + longName + `","email":"new@example.com"}`,
+			wantStatus: http.StatusBadRequest,
+			wantFields: []apperrors.FieldError{{Field: "name", Message: "must be at most 100 characters"}},
+		},
+		{
+			name:       "create with a display name in the email",
+			method:     http.MethodPost,
+			body:       `{"name":"New","email":"New <new@example.com>"}`,
+			wantStatus: http.StatusBadRequest,
+			wantFields: []apperrors.FieldError{{Field: "email", Message: "must be a valid email address"}},
+		},
+		{
+			name:       "create with an unqualified domain",
+			method:     http.MethodPost,
+			body:       `{"name":"New","email":"new@localhost"}`,
+			wantStatus: http.StatusBadRequest,
+			wantFields: []apperrors.FieldError{{Field: "email", Message: "must have a fully qualified domain"}},
+		},
+		{
+			name:       "create with an unknown field",
+			method:     http.MethodPost,
+			body:       `{"id":"abc","name":"New","email":"new@example.com"}`,
+			wantStatus: http.StatusBadRequest,
+			wantFields: []apperrors.FieldError{{Field: "id", Message: "is not allowed"}},
+		},
+		{
+			name:       "create with a wrong type",
+			method:     http.MethodPost,
+			body:       `{"name":5,"email":"new@example.com"}`,
+			wantStatus: http.StatusBadRequest,
+			wantFields: []apperrors.FieldError{{Field: "name", Message: "must be a string"}},
+		},
+		{name: "create with an empty body", method: http.MethodPost, body: ``, wantStatus: http.StatusBadRequest},
+		{name: "create with invalid JSON", method: http.MethodPost, body: `{"name":`, wantStatus: http.StatusBadRequest},
+		{name: "create with two objects", method: http.MethodPost, body: `{} {}`, wantStatus: http.StatusBadRequest},
+		{
+			name:       "replace requires every field",
+			method:     http.MethodPut,
+			body:       `{"name":"New Name"}`,
+			wantStatus: http.StatusBadRequest,
+			wantFields: []apperrors.FieldError{{Field: "email", Message: "is required"}},
+		},
+		{
+			name:       "patch keeps absent fields",
+			method:     http.MethodPatch,
+			body:       `{"name":" New   Name"}`,
+			wantStatus: http.StatusOK,
+			wantName:   "New Name",
+			wantEmail:  "jane@example.com",
+		},
+		{
+			name:       "patch normalizes the email",
+			method:     http.MethodPatch,
+			body:       `{"email":"JANE.SMITH@example.com "}`,
+			wantStatus: http.StatusOK,
+			wantName:   "Jane Smith",
+			wantEmail:  "jane.smith@example.com",
+		},
+		{
+			name:       "patch validates present fields",
+			method:     http.MethodPatch,
+			body:       `{"name":"","email":"not-an-email"}`,
+			wantStatus: http.StatusBadRequest,
+			wantFields: []apperrors.FieldError{{Field: "name", Message: "is required"}, {Field: "email", Message: "must be a valid email address"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, users, id := newTestRouter(t)
+
+			path := "/users/" + id.String()
+			if tt.method == http.MethodPost {
+				path = "/users"
+			}
+			w := serve(r, tt.method, path, "", tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			if tt.wantStatus >= http.StatusBadRequest {
+				details := decodeProblem(t, w)
+				if details.Code != string(apperrors.KindValidation) {
+					t.Errorf("code = %s, want %s", details.Code, apperrors.KindValidation)
+				}
+				if !equalFields(details.Errors, tt.wantFields) {
+					t.Errorf("errors = %v, want %v", details.Errors, tt.wantFields)
+				}
+				return
+			}
+
+			var body struct {
+				ID string `json:"id"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			stored := users.users[uuid.MustParse(body.ID)]
+			if stored.Name != tt.wantName || stored.Email != tt.wantEmail {
+				t.Errorf("stored %q <%s>, want %q <%s>", stored.Name, stored.Email, tt.wantName, tt.wantEmail)
+			}
+		})
+	}
+}
+
+func TestListUsersQueryValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantFields []apperrors.FieldError
+		wantFilter repositories.UserFilter
+	}{
+		{
+			name:       "filters are normalized",
+			query:      "?email_domain=%20@Example.COM&name_prefix=%20jo%20%20an&q=jane%20%20smith",
+			wantStatus: http.StatusOK,
+			wantFilter: repositories.UserFilter{EmailDomain: "example.com", NamePrefix: "jo an", Search: "jane smith"},
+		},
+		{
+			name:       "invalid domain",
+			query:      "?email_domain=localhost",
+			wantStatus: http.StatusBadRequest,
+			wantFields: []apperrors.FieldError{{Field: "email_domain", Message: "must be a domain such as example.com"}},
+		},
+		{
+			name:       "too many search words",
+			query:      "?q=a+b+c+d+e+f",
+			wantStatus: http.StatusBadRequest,
+			wantFields: []apperrors.FieldError{{Field: "q", Message: "must have at most 5 words"}},
+		},
+		{
+			name:       "unknown sort",
+			query:      "?sort=email",
+			wantStatus: http.StatusBadRequest,
+			wantFields: []apperrors.FieldError{{Field: "sort", Message: "must be one of created_at, -created_at, name, -name"}},
+		},
+		{
+			name:       "malformed timestamp",
+			query:      "?created_after=yesterday",
+			wantStatus: http.StatusBadRequest,
+			wantFields: []apperrors.FieldError{{Field: "created_after", Message: "must be an RFC 3339 timestamp"}},
+		},
+		{
+			name:       "empty time range",
+			query:      "?created_after=2025-01-02T00:00:00Z&created_before=2025-01-01T00:00:00Z",
+			wantStatus: http.StatusBadRequest,
+			wantFields: []apperrors.FieldError{{Field: "created_before", Message: "must be later than created_after"}},
+		},
+		{name: "limit out of range", query: "?limit=101", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, users, _ := newTestRouter(t)
+
+			w := serve(r, http.MethodGet, "/users"+tt.query, "", "")
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			if tt.wantStatus >= http.StatusBadRequest {
+				if details := decodeProblem(t, w); !equalFields(details.Errors, tt.wantFields) {
+					t.Errorf("errors = %v, want %v", details.Errors, tt.wantFields)
+				}
+				return
+			}
+			if users.query.Filter != tt.wantFilter {
+				t.Errorf("filter = %+v, want %+v", users.query.Filter, tt.wantFilter)
+			}
+		})
+	}
+}
+
+func equalFields(got, want []apperrors.FieldError) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kitamersion/go-goservice/internal/api/problem"
+	"github.com/kitamersion/go-goservice/internal/domain/idempotency"
+)
+
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderReplayed is set on responses served from the idempotency store
+	HeaderReplayed = "Idempotent-Replayed"
+)
+
+// Idempotency makes a route safe to retry: a request with an Idempotency-Key
+// header runs once, and retries with the same key and body get the original
+// response. Reusing a key with a different body is rejected with 422, and a
+// retry while the first request is still running with 409. Requests without
+// the header are passed through unchanged.
+func Idempotency(store *idempotency.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(HeaderIdempotencyKey)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if err := idempotency.ValidateKey(key); err != nil {
+			problem.Error(c, err)
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			problem.BadRequest(c, "failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := c.Request.Method + " " + c.FullPath()
+		writer := c.Writer
+
+		// The handler's response is buffered so it can be stored before it is
+		// sent, and a retry arriving right after gets it replayed
+		resp, replayed, err := store.Do(c.Request.Context(), scope, key, idempotency.Hash([]byte(scope), body), func(context.Context) (*idempotency.Response, error) {
+			buffer := newBufferedWriter(writer)
+			c.Writer = buffer
+			c.Next()
+			return buffer.response(), nil
+		})
+		c.Writer = writer
+		if err != nil {
+			problem.Error(c, err)
+			return
+		}
+
+		for name, value := range resp.Headers {
+			c.Header(name, value)
+		}
+		if replayed {
+			c.Header(HeaderReplayed, "true")
+		}
+		// A replayed response must not fall through to the handler
+		c.Abort()
+		c.Writer.WriteHeader(resp.StatusCode)
+		_, _ = c.Writer.Write(resp.Body)
+	}
+}
+
+// bufferedWriter holds back the status, headers and body written by the
+// handler.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func newBufferedWriter(w gin.ResponseWriter) *bufferedWriter {
+	return &bufferedWriter{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+		header:         make(http.Header),
+	}
+}
+
+func (w *bufferedWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.body.Len() > 0
+}
+
+func (w *bufferedWriter) response() *idempotency.Response {
+	headers := make(map[string]string, len(w.header))
+	for name := range w.header {
+		headers[name] = w.header.Get(name)
+	}
+	return &idempotency.Response{
+		StatusCode: w.status,
+		Headers:    headers,
+		Body:       w.body.Bytes(),
+	}
+}
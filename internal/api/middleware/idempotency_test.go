@@ -0,0 +1,200 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
+	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"github.com/kitamersion/go-goservice/internal/domain/idempotency"
+	"github.com/kitamersion/go-goservice/internal/domain/repositories"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeKeys keeps idempotency keys in memory.
+type fakeKeys struct {
+	repositories.IdempotencyRepository
+	keys map[string]entities.IdempotencyKey
+}
+
+func (f *fakeKeys) Reserve(key *entities.IdempotencyKey, staleBefore time.Time) (bool, error) {
+	existing, ok := f.keys[key.Scope+" "+key.Key]
+	if ok && (existing.StatusCode != 0 || !existing.CreatedAt.Before(staleBefore)) {
+		return false, nil
+	}
+	f.keys[key.Scope+" "+key.Key] = *key
+	return true, nil
+}
+
+func (f *fakeKeys) Get(scope, key string) (*entities.IdempotencyKey, error) {
+	existing, ok := f.keys[scope+" "+key]
+	if !ok {
+		return nil, apperrors.NotFound("idempotency key not found")
+	}
+	return &existing, nil
+}
+
+func (f *fakeKeys) Complete(key *entities.IdempotencyKey) error {
+	existing := f.keys[key.Scope+" "+key.Key]
+	existing.StatusCode = key.StatusCode
+	existing.Headers = key.Headers
+	existing.Body = key.Body
+	f.keys[key.Scope+" "+key.Key] = existing
+	return nil
+}
+
+func (f *fakeKeys) Release(scope, key string) error {
+	if f.keys[scope+" "+key].StatusCode == 0 {
+		delete(f.keys, scope+" "+key)
+	}
+	return nil
+}
+
+// newTestRouter serves POST /users behind the middleware with a handler that
+// answers status and counts its calls.
+func newTestRouter(keys *fakeKeys, status int, calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	r := gin.New()
+	r.POST("/users", Idempotency(idempotency.NewStore(keys, time.Hour, logger)), func(c *gin.Context) {
+		*calls++
+		c.Header("Location", "/users/1")
+		c.JSON(status, gin.H{"call": *calls})
+	})
+	return r
+}
+
+func post(r http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(HeaderIdempotencyKey, key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency(t *testing.T) {
+	const body = `{"name":"Jane"}`
+
+	tests := []struct {
+		name       string
+		status     int                                // answered by the handler
+		existing   map[string]entities.IdempotencyKey // keys stored before the requests
+		requests   []string                           // keys of successive requests with body
+		lastBody   string                             // body of the last request, if not body
+		wantStatus int                                // of the last request
+		wantCalls  int
+		replayed   bool
+	}{
+		{
+			name:       "retry is replayed",
+			status:     http.StatusCreated,
+			requests:   []string{"k1", "k1"},
+			wantStatus: http.StatusCreated,
+			wantCalls:  1,
+			replayed:   true,
+		},
+		{
+			name:       "different keys both run",
+			status:     http.StatusCreated,
+			requests:   []string{"k1", "k2"},
+			wantStatus: http.StatusCreated,
+			wantCalls:  2,
+		},
+		{
+			name:       "requests without a key both run",
+			status:     http.StatusCreated,
+			requests:   []string{"", ""},
+			wantStatus: http.StatusCreated,
+			wantCalls:  2,
+		},
+		{
+			name:       "reused key with a different body",
+			status:     http.StatusCreated,
+			requests:   []string{"k1", "k1"},
+			lastBody:   `{"name":"John"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCalls:  1,
+		},
+		{
+			name:       "server errors are not stored",
+			status:     http.StatusServiceUnavailable,
+			requests:   []string{"k1", "k1"},
+			wantStatus: http.StatusServiceUnavailable,
+			wantCalls:  2,
+		},
+		{
+			name:   "key in progress",
+			status: http.StatusCreated,
+			existing: map[string]entities.IdempotencyKey{
+				"POST /users k1": {RequestHash: idempotency.Hash([]byte("POST /users"), []byte(body)), CreatedAt: time.Now()},
+			},
+			requests:   []string{"k1"},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:   "stale claim is taken over",
+			status: http.StatusCreated,
+			existing: map[string]entities.IdempotencyKey{
+				"POST /users k1": {RequestHash: idempotency.Hash([]byte("POST /users"), []byte(body)), CreatedAt: time.Now().Add(-time.Hour)},
+			},
+			requests:   []string{"k1"},
+			wantStatus: http.StatusCreated,
+			wantCalls:  1,
+		},
+		{
+			name:       "invalid key",
+			status:     http.StatusCreated,
+			requests:   []string{strings.Repeat("k", idempotency.MaxKeyLength+1)},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := &fakeKeys{keys: make(map[string]entities.IdempotencyKey)}
+			for k, v := range tt.existing {
+				keys.keys[k] = v
+			}
+			calls := 0
+			r := newTestRouter(keys, tt.status, &calls)
+
+			var first, w *httptest.ResponseRecorder
+			for i, key := range tt.requests {
+				reqBody := body
+				if i == len(tt.requests)-1 && tt.lastBody != "" {
+					reqBody = tt.lastBody
+				}
+				w = post(r, key, reqBody)
+				if first == nil {
+					first = w
+				}
+			}
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handler ran %d times, want %d", calls, tt.wantCalls)
+			}
+			if got := w.Header().Get(HeaderReplayed) == "true"; got != tt.replayed {
+				t.Errorf("%s = %v, want %v", HeaderReplayed, got, tt.replayed)
+			}
+			if tt.replayed {
+				if w.Body.String() != first.Body.String() || w.Header().Get("Location") != first.Header().Get("Location") {
+					t.Errorf("replayed %q with Location %q, want %q with Location %q",
+						w.Body, w.Header().Get("Location"), first.Body, first.Header().Get("Location"))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kitamersion/go-goservice/internal/api/dto"
+	"github.com/kitamersion/go-goservice/internal/api/problem"
+)
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	uuidType    = reflect.TypeOf(uuid.UUID{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+	requestType = reflect.TypeOf((*dto.Request)(nil)).Elem()
+)
+
+// componentNames overrides component names where the Go type name alone is
+// not descriptive.
+var componentNames = map[reflect.Type]string{
+	reflect.TypeOf(problem.Details{}): "Problem",
+}
+
+func componentName(t reflect.Type) string {
+	if name, ok := componentNames[t]; ok {
+		return name
+	}
+	return t.Name()
+}
+
+// schemaRegistry turns Go types into schemas, registering every struct as a
+// named component so it is referenced rather than inlined.
+type schemaRegistry struct {
+	schemas map[string]*Schema
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{schemas: make(map[string]*Schema)}
+}
+
+// ref returns a reference to the component schema of v's type.
+func (r *schemaRegistry) ref(v any) *Schema {
+	return r.schemaOf(reflect.TypeOf(v))
+}
+
+// component returns the registered schema of v's type so callers can refine it.
+func (r *schemaRegistry) component(v any) *Schema {
+	t := reflect.TypeOf(v)
+	r.schemaOf(t)
+	return r.schemas[componentName(t)]
+}
+
+func (r *schemaRegistry) schemaOf(t reflect.Type) *Schema {
+	switch {
+	case t.Kind() == reflect.Pointer:
+		return r.schemaOf(t.Elem())
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case t == rawJSONType:
+		return &Schema{} // any JSON value
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: r.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		name := componentName(t)
+		if _, ok := r.schemas[name]; !ok {
+			r.schemas[name] = nil // guards against recursive types
+			r.schemas[name] = r.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+// structSchema follows encoding/json: fields are named by their json tag and
+// are required unless they are pointers or marked omitempty. Request types
+// reject unknown fields (see dto.Decode), so they disallow extra properties.
+func (r *schemaRegistry) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		s.Properties[name] = r.schemaOf(field.Type)
+		if field.Type.Kind() != reflect.Pointer && !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	if reflect.PointerTo(t).Implements(requestType) {
+		closed := false
+		s.AdditionalProperties = &closed
+	}
+	return s
+}
@@ -0,0 +1,291 @@
+package openapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kitamersion/go-goservice/internal/api/dto"
+	"github.com/kitamersion/go-goservice/internal/api/problem"
+	"github.com/kitamersion/go-goservice/internal/domain/idempotency"
+)
+
+const (
+	SpecPath = "/openapi.json"
+	DocsPath = "/docs"
+)
+
+// Build returns the OpenAPI document for every route registered in cmd/api.
+// Adding a route there without describing it here fails Verify.
+func Build() *Document {
+	b := &builder{
+		schemas: newSchemaRegistry(),
+		doc: &Document{
+			OpenAPI: Version,
+			Info: Info{
+				Title:       "kita-goservice REST API",
+				Description: "User management API. Errors are returned as RFC 7807 problem documents.",
+				Version:     "1.0.0",
+			},
+			Paths: make(map[string]PathItem),
+		},
+	}
+
+	b.add(http.MethodGet, "/health", &Operation{
+		OperationID: "healthCheck",
+		Summary:     "Liveness probe",
+		Tags:        []string{"system"},
+		Responses: map[string]*Response{
+			"200": {
+				Description: "The service is up",
+				Content: jsonContent(&Schema{
+					Type:       "object",
+					Properties: map[string]*Schema{"status": {Type: "string"}},
+					Required:   []string{"status"},
+				}),
+			},
+		},
+	})
+	b.add(http.MethodGet, SpecPath, &Operation{
+		OperationID: "getOpenAPIDocument",
+		Summary:     "This OpenAPI document",
+		Tags:        []string{"system"},
+		Responses: map[string]*Response{
+			"200": {Description: "OpenAPI 3.1 document", Content: jsonContent(&Schema{Type: "object"})},
+		},
+	})
+	b.add(http.MethodGet, DocsPath, &Operation{
+		OperationID: "getAPIDocs",
+		Summary:     "Interactive API documentation",
+		Tags:        []string{"system"},
+		Responses: map[string]*Response{
+			"200": {Description: "Swagger UI page", Content: map[string]*MediaType{"text/html": {Schema: &Schema{Type: "string"}}}},
+		},
+	})
+
+	userID := &Parameter{
+		Name:     "id",
+		In:       "path",
+		Required: true,
+		Schema:   &Schema{Type: "string", Format: "uuid"},
+	}
+	user := b.schemas.ref(dto.UserResponse{})
+	ifMatch := &Parameter{
+		Name:        "If-Match",
+		In:          "header",
+		Description: "Only apply the change if the user still has one of these ETags",
+		Schema:      &Schema{Type: "string"},
+	}
+	ifNoneMatch := &Parameter{
+		Name:        "If-None-Match",
+		In:          "header",
+		Description: "Answer 304 if the user still has one of these ETags, compared weakly",
+		Schema:      &Schema{Type: "string"},
+	}
+
+	b.add(http.MethodGet, "/api/v1/users", &Operation{
+		OperationID: "listUsers",
+		Summary:     "List users",
+		Description: "Pages with an opaque cursor; passing offset switches to offset paging, kept for backwards compatibility.",
+		Tags:        []string{"users"},
+		Parameters: []*Parameter{
+			{Name: "limit", In: "query", Description: "Page size", Schema: &Schema{Type: "integer", Minimum: intPtr(1), Maximum: intPtr(100), Default: 20}},
+			{Name: "cursor", In: "query", Description: "pagination.next_cursor of the previous page", Schema: &Schema{Type: "string"}},
+			{Name: "offset", In: "query", Description: "Deprecated: use cursor", Schema: &Schema{Type: "integer", Minimum: intPtr(0)}},
+			{Name: "email_domain", In: "query", Description: "Only users whose email is at this domain", Schema: &Schema{Type: "string", MaxLength: intPtr(dto.MaxDomainLength)}},
+			{Name: "name_prefix", In: "query", Description: "Only users whose name starts with this, ignoring case", Schema: &Schema{Type: "string", MaxLength: intPtr(dto.MaxNameLength)}},
+			{Name: "created_after", In: "query", Description: "Only users created at or after this time", Schema: &Schema{Type: "string", Format: "date-time"}},
+			{Name: "created_before", In: "query", Description: "Only users created before this time", Schema: &Schema{Type: "string", Format: "date-time"}},
+			{Name: "q", In: "query", Description: "Words that must all occur in the name or email, ignoring case", Schema: &Schema{Type: "string", MaxLength: intPtr(dto.MaxSearchLength)}},
+			{Name: "sort", In: "query", Description: "Sort order; a cursor only works with the sort it was issued for", Schema: &Schema{Type: "string", Enum: []any{"created_at", "-created_at", "name", "-name"}, Default: "created_at"}},
+		},
+		Responses: b.responses(map[string]*Response{
+			"200": {Description: "A page of users", Content: jsonContent(b.schemas.ref(dto.ListUsersResponse{}))},
+		}, http.StatusBadRequest),
+	})
+	b.add(http.MethodPost, "/api/v1/users", &Operation{
+		OperationID: "createUser",
+		Summary:     "Create a user",
+		Description: "Retries carrying the same Idempotency-Key and body return the original response with Idempotent-Replayed: true, or 409 while the first request is still running.",
+		Tags:        []string{"users"},
+		Parameters: []*Parameter{{
+			Name:        "Idempotency-Key",
+			In:          "header",
+			Description: "Client-chosen key that makes the request safe to retry",
+			Schema:      &Schema{Type: "string", MaxLength: intPtr(idempotency.MaxKeyLength)},
+		}},
+		RequestBody: b.requestBody(&dto.CreateUserRequest{}),
+		Responses: b.responses(map[string]*Response{
+			"201": {Description: "The created user", Headers: etagHeader(), Content: jsonContent(user)},
+		}, http.StatusBadRequest, http.StatusConflict, http.StatusUnprocessableEntity),
+	})
+	b.add(http.MethodGet, "/api/v1/users/:id", &Operation{
+		OperationID: "getUser",
+		Summary:     "Get a user",
+		Tags:        []string{"users"},
+		Parameters:  []*Parameter{userID, ifNoneMatch},
+		Responses: b.responses(map[string]*Response{
+			"200": {Description: "The user", Headers: etagHeader(), Content: jsonContent(user)},
+			"304": {Description: "The user has not changed", Headers: etagHeader()},
+		}, http.StatusBadRequest, http.StatusNotFound),
+	})
+	b.add(http.MethodPut, "/api/v1/users/:id", &Operation{
+		OperationID: "replaceUser",
+		Summary:     "Replace every writable field of a user",
+		Tags:        []string{"users"},
+		Parameters:  []*Parameter{userID, ifMatch},
+		RequestBody: b.requestBody(&dto.ReplaceUserRequest{}),
+		Responses: b.responses(map[string]*Response{
+			"200": {Description: "The updated user", Headers: etagHeader(), Content: jsonContent(user)},
+		}, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed),
+	})
+	b.add(http.MethodPatch, "/api/v1/users/:id", &Operation{
+		OperationID: "patchUser",
+		Summary:     "Update the fields present in the body",
+		Tags:        []string{"users"},
+		Parameters:  []*Parameter{userID, ifMatch},
+		RequestBody: b.requestBody(&dto.PatchUserRequest{}),
+		Responses: b.responses(map[string]*Response{
+			"200": {Description: "The updated user", Headers: etagHeader(), Content: jsonContent(user)},
+		}, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed),
+	})
+	b.add(http.MethodDelete, "/api/v1/users/:id", &Operation{
+		OperationID: "deleteUser",
+		Summary:     "Delete a user",
+		Tags:        []string{"users"},
+		Parameters:  []*Parameter{userID, ifMatch},
+		Responses: b.responses(map[string]*Response{
+			"204": {Description: "The user was deleted"},
+		}, http.StatusBadRequest, http.StatusNotFound, http.StatusPreconditionFailed),
+	})
+
+	jobID := &Parameter{
+		Name:     "id",
+		In:       "path",
+		Required: true,
+		Schema:   &Schema{Type: "string", Format: "uuid"},
+	}
+	importJob := b.schemas.ref(dto.ImportJobResponse{})
+
+	b.add(http.MethodPost, "/api/v1/users:import", &Operation{
+		OperationID: "importUsers",
+		Summary:     "Import users in bulk",
+		Description: "Queues an import of one user per NDJSON line or CSV row (with a name,email header) and answers with the job to poll. " +
+			"Invalid rows and existing emails are reported per row without stopping the import.",
+		Tags: []string{"users"},
+		RequestBody: &RequestBody{
+			Required: true,
+			Content: map[string]*MediaType{
+				"application/x-ndjson": {Schema: &Schema{Type: "string"}},
+				"text/csv":             {Schema: &Schema{Type: "string"}},
+			},
+		},
+		Responses: b.responses(map[string]*Response{
+			"202": {
+				Description: "The queued import job",
+				Headers: map[string]*Header{
+					"Location": {Description: "URL of the job", Schema: &Schema{Type: "string"}},
+				},
+				Content: jsonContent(importJob),
+			},
+		}, http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType),
+	})
+	b.add(http.MethodGet, "/api/v1/jobs/:id", &Operation{
+		OperationID: "getImportJob",
+		Summary:     "Get the progress of an import job",
+		Tags:        []string{"jobs"},
+		Parameters:  []*Parameter{jobID},
+		Responses: b.responses(map[string]*Response{
+			"200": {Description: "The import job", Content: jsonContent(importJob)},
+		}, http.StatusBadRequest, http.StatusNotFound),
+	})
+
+	b.add(http.MethodGet, "/api/v1/events", &Operation{
+		OperationID: "listEvents",
+		Summary:     "List recorded events",
+		Description: "Oldest first, paged with an opaque cursor like listUsers.",
+		Tags:        []string{"events"},
+		Parameters: []*Parameter{
+			{Name: "limit", In: "query", Description: "Page size", Schema: &Schema{Type: "integer", Minimum: intPtr(1), Maximum: intPtr(100), Default: 20}},
+			{Name: "cursor", In: "query", Description: "pagination.next_cursor of the previous page", Schema: &Schema{Type: "string"}},
+			{Name: "type", In: "query", Description: "Only events of this type, e.g. userpb.UserCreated", Schema: &Schema{Type: "string"}},
+		},
+		Responses: b.responses(map[string]*Response{
+			"200": {Description: "A page of events", Content: jsonContent(b.schemas.ref(dto.ListEventsResponse{}))},
+		}, http.StatusBadRequest),
+	})
+
+	b.applyUserFieldRules(dto.CreateUserRequest{}, dto.ReplaceUserRequest{}, dto.PatchUserRequest{})
+
+	b.doc.Components.Schemas = b.schemas.schemas
+	return b.doc
+}
+
+type builder struct {
+	doc     *Document
+	schemas *schemaRegistry
+}
+
+// add registers op under a gin-style path (":id"), stored in OpenAPI form
+// ("{id}").
+func (b *builder) add(method, ginPath string, op *Operation) {
+	path := toOpenAPIPath(ginPath)
+	item, ok := b.doc.Paths[path]
+	if !ok {
+		item = make(PathItem)
+		b.doc.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+}
+
+func (b *builder) requestBody(req dto.Request) *RequestBody {
+	return &RequestBody{
+		Required: true,
+		Content:  jsonContent(b.schemas.ref(req)),
+	}
+}
+
+// responses adds problem responses for the given statuses, plus the ones any
+// endpoint touching the database can return.
+func (b *builder) responses(ok map[string]*Response, statuses ...int) map[string]*Response {
+	statuses = append(statuses, http.StatusInternalServerError, http.StatusServiceUnavailable)
+	for _, status := range statuses {
+		ok[strconv.Itoa(status)] = &Response{
+			Description: http.StatusText(status),
+			Content: map[string]*MediaType{
+				problem.ContentType: {Schema: b.schemas.ref(problem.Details{})},
+			},
+		}
+	}
+	return ok
+}
+
+// applyUserFieldRules mirrors the validation rules of the dto package.
+func (b *builder) applyUserFieldRules(requests ...any) {
+	for _, req := range requests {
+		s := b.schemas.component(req)
+		if name, ok := s.Properties["name"]; ok {
+			name.MinLength = intPtr(1)
+			name.MaxLength = intPtr(dto.MaxNameLength)
+		}
+		if email, ok := s.Properties["email"]; ok {
+			email.Format = "email"
+			email.MaxLength = intPtr(dto.MaxEmailLength)
+		}
+	}
+}
+
+// etagHeader describes the ETag returned with a user, its quoted version.
+func etagHeader() map[string]*Header {
+	return map[string]*Header{
+		"ETag": {Description: `Current version of the user, e.g. "3"`, Schema: &Schema{Type: "string"}},
+	}
+}
+
+func jsonContent(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: schema}}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
@@ -0,0 +1,220 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Only a colon starting a path segment is a parameter; a colon inside one is
+// part of a custom method such as "/users:import".
+var ginParam = regexp.MustCompile(`/[:*]([A-Za-z0-9_]+)`)
+
+// toOpenAPIPath converts gin path parameters (":id", "*path") to OpenAPI
+// templates ("{id}").
+func toOpenAPIPath(ginPath string) string {
+	return ginParam.ReplaceAllString(ginPath, "/{$1}")
+}
+
+// Contract names the JSON bodies a route's handler actually decodes and
+// writes, so Verify can tell when the document describes other types.
+type Contract struct {
+	Request   any         // decoded from the request body; nil when there is none
+	Responses map[int]any // written on success, by status
+}
+
+// Verify reports every route registered on the router that the document does
+// not describe, and every documented operation that has no route. It also
+// checks that path parameters are declared for each operation, and compares
+// the documented JSON bodies with the types in contracts, which are keyed by
+// method and gin path, e.g. "GET /api/v1/users/:id".
+func Verify(doc *Document, routes gin.RoutesInfo, contracts map[string]Contract) error {
+	var problems []string
+
+	registered := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		path := toOpenAPIPath(route.Path)
+		method := strings.ToLower(route.Method)
+		registered[method+" "+path] = true
+
+		op, ok := doc.Paths[path][method]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s %s is registered but not documented", route.Method, route.Path))
+			continue
+		}
+		for _, match := range ginParam.FindAllStringSubmatch(route.Path, -1) {
+			if !hasPathParameter(op, match[1]) {
+				problems = append(problems, fmt.Sprintf("%s %s does not document path parameter %q", route.Method, route.Path, match[1]))
+			}
+		}
+
+		name := route.Method + " " + route.Path
+		contract, ok := contracts[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s has no contract", name))
+			continue
+		}
+		problems = append(problems, verifyContract(doc, name, op, contract)...)
+	}
+
+	for path, item := range doc.Paths {
+		for method := range item {
+			if !registered[method+" "+path] {
+				problems = append(problems, fmt.Sprintf("%s %s is documented but not registered", strings.ToUpper(method), path))
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return errors.New(strings.Join(problems, "; "))
+}
+
+func hasPathParameter(op *Operation, name string) bool {
+	for _, param := range op.Parameters {
+		if param.In == "path" && param.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyContract compares the JSON request body and success responses of op
+// with the contract of its handler.
+func verifyContract(doc *Document, name string, op *Operation, contract Contract) []string {
+	var problems []string
+
+	var documented *Schema
+	if op.RequestBody != nil {
+		documented = jsonSchema(op.RequestBody.Content)
+	}
+	switch {
+	case contract.Request == nil && documented != nil:
+		problems = append(problems, fmt.Sprintf("%s documents a JSON request body its handler does not read", name))
+	case contract.Request != nil && documented == nil:
+		problems = append(problems, fmt.Sprintf("%s does not document its JSON request body", name))
+	case contract.Request != nil:
+		problems = append(problems, compareSchemas(doc, name+" request body", documented, contract.Request)...)
+	}
+
+	for status, response := range op.Responses {
+		code, err := strconv.Atoi(status)
+		if err != nil || code < 200 || code > 299 {
+			continue // errors are problem documents, the same for every route
+		}
+		if _, ok := contract.Responses[code]; !ok && jsonSchema(response.Content) != nil {
+			problems = append(problems, fmt.Sprintf("%s documents a %d JSON response its handler does not write", name, code))
+		}
+	}
+	for code, v := range contract.Responses {
+		response, ok := op.Responses[strconv.Itoa(code)]
+		if !ok || jsonSchema(response.Content) == nil {
+			problems = append(problems, fmt.Sprintf("%s does not document its %d JSON response", name, code))
+			continue
+		}
+		problems = append(problems, compareSchemas(doc, fmt.Sprintf("%s %d response", name, code), jsonSchema(response.Content), v)...)
+	}
+	return problems
+}
+
+func jsonSchema(content map[string]*MediaType) *Schema {
+	if media, ok := content["application/json"]; ok {
+		return media.Schema
+	}
+	return nil
+}
+
+// compareSchemas reflects the schema of v and reports where the documented
+// schema differs from it: in types, properties and which of them are
+// required. Descriptions, formats and validation keywords are not compared,
+// as Build refines those by hand.
+func compareSchemas(doc *Document, at string, documented *Schema, v any) []string {
+	reflected := newSchemaRegistry()
+	c := schemaComparison{
+		documented: doc.Components.Schemas,
+		reflected:  reflected.schemas,
+		seen:       make(map[[2]string]bool),
+	}
+	c.compare(at, documented, reflected.schemaOf(reflect.TypeOf(v)))
+	return c.problems
+}
+
+type schemaComparison struct {
+	documented map[string]*Schema
+	reflected  map[string]*Schema
+	seen       map[[2]string]bool // pairs of components already compared
+	problems   []string
+}
+
+func (c *schemaComparison) compare(at string, documented, reflected *Schema) {
+	if documented.Ref != "" || reflected.Ref != "" {
+		pair := [2]string{documented.Ref, reflected.Ref}
+		if c.seen[pair] {
+			return
+		}
+		c.seen[pair] = true
+	}
+	documented = resolve(documented, c.documented)
+	reflected = resolve(reflected, c.reflected)
+	if documented == nil || reflected == nil {
+		c.problems = append(c.problems, fmt.Sprintf("%s refers to a missing component", at))
+		return
+	}
+
+	if documented.Type != reflected.Type {
+		c.problems = append(c.problems, fmt.Sprintf("%s is %s in the document but %s in the handler's type", at, describeType(documented), describeType(reflected)))
+		return
+	}
+	if !reflect.DeepEqual(documented.AdditionalProperties, reflected.AdditionalProperties) {
+		c.problems = append(c.problems, fmt.Sprintf("%s differs from the handler's type in whether it allows unknown properties", at))
+	}
+
+	for name, property := range reflected.Properties {
+		field := at + " field " + name
+		documentedProperty, ok := documented.Properties[name]
+		if !ok {
+			c.problems = append(c.problems, fmt.Sprintf("%s is missing from the document", field))
+			continue
+		}
+		switch required := slices.Contains(reflected.Required, name); {
+		case required && !slices.Contains(documented.Required, name):
+			c.problems = append(c.problems, fmt.Sprintf("%s is required by the handler's type but optional in the document", field))
+		case !required && slices.Contains(documented.Required, name):
+			c.problems = append(c.problems, fmt.Sprintf("%s is optional in the handler's type but required in the document", field))
+		}
+		c.compare(field, documentedProperty, property)
+	}
+	for name := range documented.Properties {
+		if _, ok := reflected.Properties[name]; !ok {
+			c.problems = append(c.problems, fmt.Sprintf("%s field %s is documented but not in the handler's type", at, name))
+		}
+	}
+
+	if documented.Items != nil && reflected.Items != nil {
+		c.compare(at+" items", documented.Items, reflected.Items)
+	}
+}
+
+func resolve(s *Schema, components map[string]*Schema) *Schema {
+	name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/")
+	if !ok {
+		return s
+	}
+	return components[name]
+}
+
+func describeType(s *Schema) string {
+	if s.Type == "" {
+		return "any JSON value"
+	}
+	return s.Type
+}
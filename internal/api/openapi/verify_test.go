@@ -0,0 +1,231 @@
+package openapi
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type widget struct {
+	ID   string  `json:"id"`
+	Name string  `json:"name"`
+	Note *string `json:"note"`
+}
+
+type widgetPage struct {
+	Data []widget `json:"data"`
+}
+
+// The same widget after changes the document did not pick up
+type (
+	widgetAdded struct {
+		ID    string  `json:"id"`
+		Name  string  `json:"name"`
+		Note  *string `json:"note"`
+		Color string  `json:"color"`
+	}
+	widgetRenamed struct {
+		ID    string  `json:"id"`
+		Title string  `json:"title"`
+		Note  *string `json:"note"`
+	}
+	widgetRetyped struct {
+		ID   int64   `json:"id"`
+		Name string  `json:"name"`
+		Note *string `json:"note"`
+	}
+	widgetOptional struct {
+		ID   string  `json:"id"`
+		Name string  `json:"name,omitempty"`
+		Note *string `json:"note"`
+	}
+	widgetPageAdded struct {
+		Data []widgetAdded `json:"data"`
+	}
+)
+
+// testDocument describes GET /widgets, POST /widgets and GET /widgets/:id.
+func testDocument() *Document {
+	b := &builder{
+		schemas: newSchemaRegistry(),
+		doc:     &Document{OpenAPI: Version, Paths: make(map[string]PathItem)},
+	}
+	id := &Parameter{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string"}}
+
+	b.add(http.MethodGet, "/widgets", &Operation{
+		OperationID: "listWidgets",
+		Responses: b.responses(map[string]*Response{
+			"200": {Description: "A page of widgets", Content: jsonContent(b.schemas.ref(widgetPage{}))},
+		}),
+	})
+	b.add(http.MethodPost, "/widgets", &Operation{
+		OperationID: "createWidget",
+		RequestBody: &RequestBody{Required: true, Content: jsonContent(b.schemas.ref(widget{}))},
+		Responses: b.responses(map[string]*Response{
+			"201": {Description: "The created widget", Content: jsonContent(b.schemas.ref(widget{}))},
+		}, http.StatusBadRequest),
+	})
+	b.add(http.MethodGet, "/widgets/:id", &Operation{
+		OperationID: "getWidget",
+		Parameters:  []*Parameter{id},
+		Responses: b.responses(map[string]*Response{
+			"200": {Description: "The widget", Content: jsonContent(b.schemas.ref(widget{}))},
+			"304": {Description: "The widget has not changed"},
+		}, http.StatusNotFound),
+	})
+
+	b.doc.Components.Schemas = b.schemas.schemas
+	return b.doc
+}
+
+func testRoutes() gin.RoutesInfo {
+	return gin.RoutesInfo{
+		{Method: http.MethodGet, Path: "/widgets"},
+		{Method: http.MethodPost, Path: "/widgets"},
+		{Method: http.MethodGet, Path: "/widgets/:id"},
+	}
+}
+
+func testContracts() map[string]Contract {
+	return map[string]Contract{
+		"GET /widgets":     {Responses: map[int]any{http.StatusOK: widgetPage{}}},
+		"POST /widgets":    {Request: widget{}, Responses: map[int]any{http.StatusCreated: widget{}}},
+		"GET /widgets/:id": {Responses: map[int]any{http.StatusOK: widget{}}},
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(doc *Document, routes *gin.RoutesInfo, contracts map[string]Contract)
+		want   []string // each must occur in the error; none means no error
+	}{
+		{
+			name:   "in sync",
+			change: func(*Document, *gin.RoutesInfo, map[string]Contract) {},
+		},
+		{
+			name: "route not documented",
+			change: func(_ *Document, routes *gin.RoutesInfo, contracts map[string]Contract) {
+				*routes = append(*routes, gin.RouteInfo{Method: http.MethodDelete, Path: "/widgets/:id"})
+				contracts["DELETE /widgets/:id"] = Contract{}
+			},
+			want: []string{"DELETE /widgets/:id is registered but not documented"},
+		},
+		{
+			name: "operation not registered",
+			change: func(_ *Document, routes *gin.RoutesInfo, _ map[string]Contract) {
+				*routes = (*routes)[:2]
+			},
+			want: []string{"GET /widgets/{id} is documented but not registered"},
+		},
+		{
+			name: "path parameter not documented",
+			change: func(doc *Document, _ *gin.RoutesInfo, _ map[string]Contract) {
+				doc.Paths["/widgets/{id}"]["get"].Parameters = nil
+			},
+			want: []string{`GET /widgets/:id does not document path parameter "id"`},
+		},
+		{
+			name: "route without a contract",
+			change: func(_ *Document, _ *gin.RoutesInfo, contracts map[string]Contract) {
+				delete(contracts, "GET /widgets/:id")
+			},
+			want: []string{"GET /widgets/:id has no contract"},
+		},
+		{
+			name: "response field added",
+			change: func(_ *Document, _ *gin.RoutesInfo, contracts map[string]Contract) {
+				contracts["GET /widgets/:id"] = Contract{Responses: map[int]any{http.StatusOK: widgetAdded{}}}
+			},
+			want: []string{"GET /widgets/:id 200 response field color is missing from the document"},
+		},
+		{
+			name: "response field renamed",
+			change: func(_ *Document, _ *gin.RoutesInfo, contracts map[string]Contract) {
+				contracts["GET /widgets/:id"] = Contract{Responses: map[int]any{http.StatusOK: widgetRenamed{}}}
+			},
+			want: []string{
+				"GET /widgets/:id 200 response field title is missing from the document",
+				"GET /widgets/:id 200 response field name is documented but not in the handler's type",
+			},
+		},
+		{
+			name: "response field retyped",
+			change: func(_ *Document, _ *gin.RoutesInfo, contracts map[string]Contract) {
+				contracts["GET /widgets/:id"] = Contract{Responses: map[int]any{http.StatusOK: widgetRetyped{}}}
+			},
+			want: []string{"GET /widgets/:id 200 response field id is string in the document but integer in the handler's type"},
+		},
+		{
+			name: "response field made optional",
+			change: func(_ *Document, _ *gin.RoutesInfo, contracts map[string]Contract) {
+				contracts["GET /widgets/:id"] = Contract{Responses: map[int]any{http.StatusOK: widgetOptional{}}}
+			},
+			want: []string{"GET /widgets/:id 200 response field name is optional in the handler's type but required in the document"},
+		},
+		{
+			name: "field added to list items",
+			change: func(_ *Document, _ *gin.RoutesInfo, contracts map[string]Contract) {
+				contracts["GET /widgets"] = Contract{Responses: map[int]any{http.StatusOK: widgetPageAdded{}}}
+			},
+			want: []string{"GET /widgets 200 response field data items field color is missing from the document"},
+		},
+		{
+			name: "request field added",
+			change: func(_ *Document, _ *gin.RoutesInfo, contracts map[string]Contract) {
+				contracts["POST /widgets"] = Contract{Request: widgetAdded{}, Responses: map[int]any{http.StatusCreated: widget{}}}
+			},
+			want: []string{"POST /widgets request body field color is missing from the document"},
+		},
+		{
+			name: "request body not documented",
+			change: func(doc *Document, _ *gin.RoutesInfo, _ map[string]Contract) {
+				doc.Paths["/widgets"]["post"].RequestBody = nil
+			},
+			want: []string{"POST /widgets does not document its JSON request body"},
+		},
+		{
+			name: "request body not read",
+			change: func(_ *Document, _ *gin.RoutesInfo, contracts map[string]Contract) {
+				contracts["POST /widgets"] = Contract{Responses: map[int]any{http.StatusCreated: widget{}}}
+			},
+			want: []string{"POST /widgets documents a JSON request body its handler does not read"},
+		},
+		{
+			name: "response status not documented",
+			change: func(_ *Document, _ *gin.RoutesInfo, contracts map[string]Contract) {
+				contracts["POST /widgets"] = Contract{Request: widget{}, Responses: map[int]any{http.StatusOK: widget{}}}
+			},
+			want: []string{
+				"POST /widgets does not document its 200 JSON response",
+				"POST /widgets documents a 201 JSON response its handler does not write",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, routes, contracts := testDocument(), testRoutes(), testContracts()
+			tt.change(doc, &routes, contracts)
+
+			err := Verify(doc, routes, contracts)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Verify() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Verify() = nil, want %q", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Verify() = %v, want it to report %q", err, want)
+				}
+			}
+		})
+	}
+}
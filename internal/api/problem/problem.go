@@ -0,0 +1,78 @@
+// Package problem renders errors as RFC 7807 problem details
+// (application/problem+json).
+package problem
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
+)
+
+const ContentType = "application/problem+json"
+
+// Details is an RFC 7807 problem document. Code and Errors are extension
+// members carrying the apperrors kind and field errors, the same values GraphQL
+// clients see in extensions.code and extensions.fields.
+type Details struct {
+	Type     string                 `json:"type"`
+	Title    string                 `json:"title"`
+	Status   int                    `json:"status"`
+	Detail   string                 `json:"detail,omitempty"`
+	Instance string                 `json:"instance,omitempty"`
+	Code     string                 `json:"code"`
+	Errors   []apperrors.FieldError `json:"errors,omitempty"`
+}
+
+var statusByKind = map[apperrors.Kind]int{
+	apperrors.KindNotFound:      http.StatusNotFound,
+	apperrors.KindConflict:      http.StatusConflict,
+	apperrors.KindValidation:    http.StatusBadRequest,
+	apperrors.KindUnprocessable: http.StatusUnprocessableEntity,
+	apperrors.KindUnavailable:   http.StatusServiceUnavailable,
+	apperrors.KindInternal:      http.StatusInternalServerError,
+}
+
+// Status returns the HTTP status code for the kind of err.
+func Status(err error) int {
+	return statusByKind[apperrors.KindOf(err)]
+}
+
+// Error aborts the request with a problem document describing err. Internal
+// errors are reported with a generic detail; the cause is attached to the
+// gin context so it shows up in the logs.
+func Error(c *gin.Context, err error) {
+	_ = c.Error(err)
+
+	kind := apperrors.KindOf(err)
+	details := newDetails(c, statusByKind[kind], kind, apperrors.Message(err, "An unexpected error occurred"))
+	details.Errors = apperrors.FieldsOf(err)
+	render(c, details)
+}
+
+// Write aborts the request with a problem document of the given status.
+func Write(c *gin.Context, status int, kind apperrors.Kind, detail string) {
+	render(c, newDetails(c, status, kind, detail))
+}
+
+func newDetails(c *gin.Context, status int, kind apperrors.Kind, detail string) Details {
+	return Details{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+		Code:     string(kind),
+	}
+}
+
+func render(c *gin.Context, details Details) {
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(details.Status, details)
+}
+
+// BadRequest is a shorthand for validation problems raised by the handlers
+// themselves, such as malformed path or query parameters.
+func BadRequest(c *gin.Context, detail string) {
+	Write(c, http.StatusBadRequest, apperrors.KindValidation, detail)
+}
@@ -2,20 +2,32 @@ package config
 
 import (
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Kafka    KafkaConfig    `mapstructure:"kafka"`
-	Logger   LoggerConfig   `mapstructure:"logger"`
+	Server      ServerConfig      `mapstructure:"server"`
+	Database    DatabaseConfig    `mapstructure:"database"`
+	Kafka       KafkaConfig       `mapstructure:"kafka"`
+	Outbox      OutboxConfig      `mapstructure:"outbox"`
+	Schema      SchemaConfig      `mapstructure:"schema_registry"`
+	Idempotency IdempotencyConfig `mapstructure:"idempotency"`
+	Imports     ImportConfig      `mapstructure:"imports"`
+	Graph       GraphConfig       `mapstructure:"graph"`
+	Logger      LoggerConfig      `mapstructure:"logger"`
 }
 
 type ServerConfig struct {
 	Port string `mapstructure:"port"`
 	Host string `mapstructure:"host"`
+	// Zero timeouts fall back to the defaults of the server package
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+	ReadTimeout       time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout      time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout       time.Duration `mapstructure:"idle_timeout"`
+	ShutdownTimeout   time.Duration `mapstructure:"shutdown_timeout"` // deadline for draining requests and stopping workers
 }
 
 type DatabaseConfig struct {
@@ -30,11 +42,109 @@ type DatabaseConfig struct {
 type KafkaConfig struct {
 	Brokers []string `mapstructure:"brokers"`
 	Topics  struct {
-		UserEvents string `mapstructure:"user_events"`
+		UserEvents    string `mapstructure:"user_events"`
+		UserEventsDLQ string `mapstructure:"user_events_dlq"`
 	} `mapstructure:"topics"`
 	ConsumerGroups struct {
 		UserConsumer string `mapstructure:"user_consumer"`
+		DLQRedrive   string `mapstructure:"dlq_redrive"`
 	} `mapstructure:"consumer_groups"`
+	// HeaderFormat is "legacy" (event_id, event_type, ...) or "cloudevents" (ce_* headers)
+	HeaderFormat string `mapstructure:"header_format"`
+	// EventSource is the CloudEvents source attribute of produced events
+	EventSource string `mapstructure:"event_source"`
+	// ContentTypes selects the payload encoding per topic, JSON by default
+	ContentTypes map[string]string `mapstructure:"content_types"`
+	Consumer     ConsumerConfig    `mapstructure:"consumer"`
+	Retry        RetryConfig       `mapstructure:"retry"`
+	Dedup        DedupConfig       `mapstructure:"dedup"`
+}
+
+type ConsumerConfig struct {
+	CommitBatchSize int           `mapstructure:"commit_batch_size"`
+	CommitInterval  time.Duration `mapstructure:"commit_interval"`
+	Concurrency     int           `mapstructure:"concurrency"` // 1 processes messages one at a time
+	OrderBy         string        `mapstructure:"order_by"`    // "key" or "partition"
+}
+
+// DedupConfig controls how long processed event IDs are remembered.
+type DedupConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`
+	TTL             time.Duration `mapstructure:"ttl"`
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
+}
+
+// RetryConfig is the default retry policy for consumer handlers.
+type RetryConfig struct {
+	MaxAttempts    int           `mapstructure:"max_attempts"`
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+}
+
+type OutboxConfig struct {
+	PollInterval   time.Duration `mapstructure:"poll_interval"`
+	BatchSize      int           `mapstructure:"batch_size"`
+	EmbeddedRelay  bool          `mapstructure:"embedded_relay"` // run the relay inside cmd/api and cmd/graph
+	MetricsPort    string        `mapstructure:"metrics_port"`
+	MaxAttempts    int           `mapstructure:"max_attempts"`    // attempts before an undeliverable event is parked
+	PublishTimeout time.Duration `mapstructure:"publish_timeout"` // bounds one Kafka write
+}
+
+type SchemaConfig struct {
+	Store         string   `mapstructure:"store"` // "file" or "postgres"
+	Path          string   `mapstructure:"path"`
+	Compatibility string   `mapstructure:"compatibility"`
+	Packages      []string `mapstructure:"packages"`
+}
+
+// IdempotencyConfig controls how long responses to requests carrying an
+// Idempotency-Key are kept for replay.
+type IdempotencyConfig struct {
+	TTL             time.Duration `mapstructure:"ttl"`
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
+}
+
+// ImportConfig controls bulk user imports.
+type ImportConfig struct {
+	BatchSize    int   `mapstructure:"batch_size"`     // rows per insert transaction
+	MaxBodyBytes int64 `mapstructure:"max_body_bytes"` // largest accepted upload
+	QueueSize    int   `mapstructure:"queue_size"`     // jobs waiting to run before uploads are refused
+	MaxRowErrors int   `mapstructure:"max_row_errors"` // row errors kept per job
+}
+
+// GraphConfig controls the GraphQL server in cmd/graph.
+type GraphConfig struct {
+	Subscriptions SubscriptionConfig `mapstructure:"subscriptions"`
+	Limits        LimitsConfig       `mapstructure:"limits"`
+
+	PersistedQueries     PersistedQueryConfig `mapstructure:"persisted_queries"`
+	DisableIntrospection bool                 `mapstructure:"disable_introspection"`
+	DisablePlayground    bool                 `mapstructure:"disable_playground"` // stops serving /playground
+}
+
+// PersistedQueryConfig locks the GraphQL server down to the operations of a
+// manifest generated by the frontend build.
+type PersistedQueryConfig struct {
+	Allowlist bool   `mapstructure:"allowlist"` // reject operations missing from the manifest
+	Manifest  string `mapstructure:"manifest"`  // JSON object of sha256 hash -> document
+}
+
+// SubscriptionConfig controls live user events served over WebSockets.
+type SubscriptionConfig struct {
+	BufferSize   int           `mapstructure:"buffer_size"`   // events queued per subscription before it is dropped
+	PingInterval time.Duration `mapstructure:"ping_interval"` // WebSocket keep-alive
+}
+
+// LimitsConfig bounds what a single operation, and a single client over time,
+// may ask of the GraphQL server. A zero limit disables that check.
+type LimitsConfig struct {
+	MaxDepth      int           `mapstructure:"max_depth"`      // nested field levels of one operation
+	MaxComplexity int           `mapstructure:"max_complexity"` // cost of one operation, see @cost in the schema
+	Budget        int           `mapstructure:"budget"`         // cost one client may spend per window
+	BudgetWindow  time.Duration `mapstructure:"budget_window"`
+	// Header that identifies the client for the budget, such as an API key
+	// set by a gateway; the remote address is used when empty
+	ClientHeader string `mapstructure:"client_header"`
 }
 
 type LoggerConfig struct {
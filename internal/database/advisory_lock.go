@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// AdvisoryLock is a session-level Postgres advisory lock. It is held on a
+// connection of its own rather than inside a transaction, so the holder can do
+// slow work such as publishing to Kafka without keeping a transaction open,
+// and Postgres releases it as soon as that connection goes away.
+//
+// The connection is taken from the pool while the lock is held.
+type AdvisoryLock struct {
+	db  *sql.DB
+	key int64
+
+	mu   sync.Mutex
+	conn *sql.Conn // non-nil while the lock is held
+}
+
+func NewAdvisoryLock(db *gorm.DB, key int64) (*AdvisoryLock, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get generic database object: %w", err)
+	}
+	return &AdvisoryLock{db: sqlDB, key: key}, nil
+}
+
+// TryAcquire reports whether this process holds the lock, taking it without
+// blocking when it is free. A lock that is already held is confirmed by
+// pinging its connection: if the connection dropped, Postgres has released the
+// lock, possibly to someone else, and it is taken again from scratch.
+func (l *AdvisoryLock) TryAcquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		if err := l.conn.PingContext(ctx); err == nil {
+			return true, nil
+		}
+		discard(l.conn)
+		l.conn = nil
+	}
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&locked); err != nil {
+		discard(conn)
+		return false, err
+	}
+	if !locked {
+		return false, conn.Close()
+	}
+	l.conn = conn
+	return true, nil
+}
+
+// Release gives up the lock, so another process can take it right away.
+func (l *AdvisoryLock) Release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+	conn := l.conn
+	l.conn = nil
+
+	if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", l.key); err != nil {
+		discard(conn)
+		return err
+	}
+	return conn.Close()
+}
+
+// discard closes the connection instead of returning it to the pool, where it
+// could still hold the lock on behalf of whoever uses it next.
+func discard(conn *sql.Conn) {
+	_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+	_ = conn.Close()
+}
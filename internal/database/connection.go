@@ -33,9 +33,54 @@ func NewConnection(cfg *config.DatabaseConfig) (*gorm.DB, error) {
 	return db, nil
 }
 
+// Close closes the connection pool once in-flight queries have finished.
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// userIndexes back the filters and sort orders of user listings: trigram
+// indexes serve the LIKE/ILIKE patterns of name, email domain and search
+// filters, and the b-tree indexes serve keyset paging in each order.
+var userIndexes = []string{
+	`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+	`CREATE INDEX IF NOT EXISTS idx_user_entities_name_trgm ON user_entities USING gin (name gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_user_entities_email_trgm ON user_entities USING gin (email gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_user_entities_created_at_id ON user_entities (created_at, id)`,
+	`CREATE INDEX IF NOT EXISTS idx_user_entities_name_id ON user_entities (name, id)`,
+}
+
+// eventIndexes serve keyset paging of the event listing, overall and per type.
+var eventIndexes = []string{
+	`CREATE INDEX IF NOT EXISTS idx_events_created_at_id ON events (created_at, id)`,
+	`CREATE INDEX IF NOT EXISTS idx_events_type_created_at_id ON events (type, created_at, id)`,
+}
+
 func RunMigrations(db *gorm.DB) error {
-	return db.AutoMigrate(
+	err := db.AutoMigrate(
 		&entities.UserEntity{},
 		&entities.Event{},
+		&entities.ProcessedEvent{},
+		&entities.SchemaVersion{},
+		&entities.IdempotencyKey{},
+		&entities.ImportJob{},
 	)
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range userIndexes {
+		if err := db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to create user index: %w", err)
+		}
+	}
+	for _, statement := range eventIndexes {
+		if err := db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to create event index: %w", err)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,113 @@
+// Package apperrors defines the kinds of failure the domain layer reports, so
+// the REST and GraphQL layers can map them to responses without knowing about
+// gorm or Postgres.
+package apperrors
+
+import "errors"
+
+// Kind classifies an error. The values double as the machine-readable code
+// returned to clients.
+type Kind string
+
+const (
+	KindInternal   Kind = "INTERNAL"
+	KindNotFound   Kind = "NOT_FOUND"
+	KindConflict   Kind = "CONFLICT"
+	KindValidation Kind = "VALIDATION"
+	// KindUnprocessable is a well-formed request that cannot be applied, such
+	// as a reused idempotency key with a different payload
+	KindUnprocessable Kind = "UNPROCESSABLE"
+	KindUnavailable   Kind = "UNAVAILABLE"
+)
+
+// FieldError describes why a single input field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is a domain error of a known kind. Message is safe to show to clients;
+// Err keeps the underlying cause for logs and errors.Is checks. Fields lists
+// per-field problems of validation errors.
+type Error struct {
+	Kind    Kind
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New returns an error of the given kind wrapping err, which may be nil.
+func New(kind Kind, message string, err error) *Error {
+	return &Error{
+		Kind:    kind,
+		Message: message,
+		Err:     err,
+	}
+}
+
+func NotFound(message string) *Error {
+	return New(KindNotFound, message, nil)
+}
+
+func Conflict(message string) *Error {
+	return New(KindConflict, message, nil)
+}
+
+func Validation(message string) *Error {
+	return New(KindValidation, message, nil)
+}
+
+// InvalidFields returns a validation error listing the rejected fields.
+func InvalidFields(fields []FieldError) *Error {
+	err := Validation("request has invalid fields")
+	err.Fields = fields
+	return err
+}
+
+func Unavailable(message string, err error) *Error {
+	return New(KindUnavailable, message, err)
+}
+
+// KindOf reports the kind of err, or KindInternal when err is not an *Error.
+func KindOf(err error) Kind {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Kind
+	}
+	return KindInternal
+}
+
+// Is reports whether err is an *Error of the given kind.
+func Is(err error, kind Kind) bool {
+	return err != nil && KindOf(err) == kind
+}
+
+// FieldsOf returns the field errors of err, if any.
+func FieldsOf(err error) []FieldError {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Fields
+	}
+	return nil
+}
+
+// Message returns the client-safe message of err, or fallback when err is not
+// an *Error.
+func Message(err error, fallback string) string {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Message
+	}
+	return fallback
+}
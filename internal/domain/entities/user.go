@@ -12,11 +12,85 @@ type UserEntity struct {
 	Name      string    `json:"name" gorm:"not null"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// Version is bumped on every update and guards against lost updates
+	Version int64 `json:"version" gorm:"not null;default:1"`
 }
 
+// Event doubles as the transactional outbox: rows are written in the same
+// transaction as the state change they describe and published to Kafka later.
 type Event struct {
-	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	Type      string    `json:"type" gorm:"not null"`
-	Payload   string    `json:"payload" gorm:"type:jsonb"`
-	CreatedAt time.Time `json:"created_at"`
+	ID               uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Type             string     `json:"type" gorm:"not null"`
+	AggregateID      string     `json:"aggregate_id" gorm:"uniqueIndex:idx_events_aggregate_version"`
+	AggregateVersion int64      `json:"aggregate_version" gorm:"uniqueIndex:idx_events_aggregate_version"`
+	Payload          string     `json:"payload" gorm:"type:jsonb"`
+	CreatedAt        time.Time  `json:"created_at"`
+	PublishedAt      *time.Time `json:"published_at" gorm:"index"`
+	Attempts         int        `json:"attempts" gorm:"not null;default:0"`
+	LastError        string     `json:"last_error"`
+	// FailedAt parks a row the relay gave up on; it is no longer published
+	FailedAt *time.Time `json:"failed_at" gorm:"index"`
+}
+
+// ProcessedEvent records that a consumer group already handled an event, so
+// redeliveries can be skipped.
+type ProcessedEvent struct {
+	EventID       string    `json:"event_id" gorm:"primaryKey"`
+	ConsumerGroup string    `json:"consumer_group" gorm:"primaryKey"`
+	ProcessedAt   time.Time `json:"processed_at" gorm:"index;not null"`
+}
+
+// SchemaVersion is one registered version of an event schema. Descriptor holds
+// a serialized FileDescriptorSet with the message's file and its imports.
+type SchemaVersion struct {
+	ID          int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Subject     string    `json:"subject" gorm:"not null;uniqueIndex:idx_schema_subject_version"`
+	Version     int       `json:"version" gorm:"not null;uniqueIndex:idx_schema_subject_version"`
+	Fingerprint string    `json:"fingerprint" gorm:"not null;index"`
+	Descriptor  []byte    `json:"descriptor" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// IdempotencyKey stores the response to a request made with an idempotency
+// key, so a retry of the same request gets the original result.
+type IdempotencyKey struct {
+	Scope       string            `json:"scope" gorm:"primaryKey"` // the operation, e.g. "POST /api/v1/users"
+	Key         string            `json:"key" gorm:"primaryKey"`
+	RequestHash string            `json:"request_hash" gorm:"not null"`
+	StatusCode  int               `json:"status_code"`
+	Headers     map[string]string `json:"headers" gorm:"serializer:json;type:jsonb"`
+	Body        []byte            `json:"body"`
+	CreatedAt   time.Time         `json:"created_at" gorm:"index;not null"`
+}
+
+// Import job statuses.
+const (
+	ImportJobQueued    = "queued"
+	ImportJobRunning   = "running"
+	ImportJobCompleted = "completed"
+	ImportJobFailed    = "failed"
+)
+
+// ImportJob tracks a bulk user import. Rows are processed in batches and the
+// counters are updated after every batch, so clients can poll progress.
+type ImportJob struct {
+	ID         uuid.UUID        `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Format     string           `json:"format" gorm:"not null"`
+	Status     string           `json:"status" gorm:"not null;index"`
+	Processed  int              `json:"processed" gorm:"not null;default:0"`
+	Succeeded  int              `json:"succeeded" gorm:"not null;default:0"`
+	Failed     int              `json:"failed" gorm:"not null;default:0"`
+	RowErrors  []ImportRowError `json:"row_errors" gorm:"serializer:json;type:jsonb"`
+	Error      string           `json:"error"` // why the whole job failed, if it did
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+	FinishedAt *time.Time       `json:"finished_at"`
+}
+
+// ImportRowError explains why one row of an import was skipped. Row numbers
+// start at 1 and count data rows, not a CSV header.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
 }
@@ -0,0 +1,188 @@
+// Package idempotency replays the stored response of a request that is retried
+// with the same idempotency key, instead of running it a second time.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
+	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"github.com/kitamersion/go-goservice/internal/domain/repositories"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	MaxKeyLength           = 255
+	defaultTTL             = 24 * time.Hour
+	defaultCleanupInterval = time.Hour
+
+	// claimTimeout is how long a key stays claimed by a request that never
+	// stored its response. It outlasts the server's write timeout, so only
+	// requests that can no longer finish lose their claim.
+	claimTimeout = 2 * time.Minute
+)
+
+// ErrKeyReused is wrapped by the error returned when a key is sent again with
+// a different request.
+var ErrKeyReused = errors.New("idempotency key reused with a different request")
+
+// ErrInProgress is wrapped by the error returned when a key is sent again
+// while the first request with it is still running.
+var ErrInProgress = errors.New("idempotency key in use by a request in progress")
+
+// Response is what gets replayed for a retried request.
+type Response struct {
+	StatusCode int
+	Headers    map[string]string
+	Body       []byte
+}
+
+// Store runs a request at most once per key. The key is claimed in a
+// statement of its own before the request runs and the response is stored in
+// another once it has finished, so no transaction or connection is held while
+// the request runs. A concurrent retry that finds the key claimed is turned
+// away with a conflict rather than waiting for the first attempt.
+//
+// A process that dies after the request's writes commit but before its
+// response is stored leaves the claim behind; once it is older than
+// claimTimeout a retry takes it over and runs the request again.
+type Store struct {
+	repo   repositories.IdempotencyRepository
+	ttl    time.Duration
+	logger *logrus.Logger
+}
+
+func NewStore(repo repositories.IdempotencyRepository, ttl time.Duration, logger *logrus.Logger) *Store {
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	return &Store{
+		repo:   repo,
+		ttl:    ttl,
+		logger: logger,
+	}
+}
+
+// Hash returns the fingerprint of a request used to detect a reused key.
+func Hash(parts ...[]byte) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ValidateKey checks a client-supplied key.
+func ValidateKey(key string) error {
+	if key == "" || len(key) > MaxKeyLength {
+		return apperrors.Validation("idempotency key must be between 1 and 255 characters")
+	}
+	return nil
+}
+
+// Do runs fn unless the key was already used within scope, in which case the
+// stored response is returned with replayed set. Responses with a 5xx status
+// or an error from fn are not stored and release the key, so the client can
+// retry them.
+func (s *Store) Do(ctx context.Context, scope, key, requestHash string, fn func(ctx context.Context) (*Response, error)) (*Response, bool, error) {
+	now := time.Now()
+	reserved, err := s.repo.Reserve(&entities.IdempotencyKey{
+		Scope:       scope,
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+	}, now.Add(-claimTimeout))
+	if err != nil {
+		return nil, false, err
+	}
+	if !reserved {
+		resp, err := s.replay(scope, key, requestHash)
+		if err != nil {
+			return nil, false, err
+		}
+		return resp, true, nil
+	}
+
+	resp, err := fn(ctx)
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		if releaseErr := s.repo.Release(scope, key); releaseErr != nil {
+			s.logger.WithError(releaseErr).WithFields(logrus.Fields{
+				"scope": scope,
+				"key":   key,
+			}).Error("Failed to release idempotency key")
+		}
+		return resp, false, err
+	}
+
+	err = s.repo.Complete(&entities.IdempotencyKey{
+		Scope:       scope,
+		Key:         key,
+		RequestHash: requestHash,
+		StatusCode:  resp.StatusCode,
+		Headers:     resp.Headers,
+		Body:        resp.Body,
+	})
+	if err != nil {
+		// The request already ran, so its response is still returned; a retry
+		// finds the key claimed until the claim times out
+		s.logger.WithError(err).WithFields(logrus.Fields{
+			"scope": scope,
+			"key":   key,
+		}).Error("Failed to store response for idempotency key")
+	}
+	return resp, false, nil
+}
+
+// replay returns the stored response of a key that is already claimed.
+func (s *Store) replay(scope, key, requestHash string) (*Response, error) {
+	existing, err := s.repo.Get(scope, key)
+	if err != nil {
+		return nil, err
+	}
+	if existing.RequestHash != requestHash {
+		return nil, apperrors.New(apperrors.KindUnprocessable, "idempotency key was already used with a different request", ErrKeyReused)
+	}
+	if existing.StatusCode == 0 {
+		return nil, apperrors.New(apperrors.KindConflict, "a request with this idempotency key is still in progress", ErrInProgress)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"scope": scope,
+		"key":   key,
+	}).Info("Replaying stored response for idempotency key")
+	return &Response{
+		StatusCode: existing.StatusCode,
+		Headers:    existing.Headers,
+		Body:       existing.Body,
+	}, nil
+}
+
+// StartCleanup deletes keys older than the TTL on every interval until ctx is
+// cancelled.
+func (s *Store) StartCleanup(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultCleanupInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := s.repo.DeleteOlderThan(time.Now().Add(-s.ttl))
+			if err != nil {
+				s.logger.WithError(err).Error("Failed to clean up idempotency keys")
+				continue
+			}
+			s.logger.Debugf("Deleted %d expired idempotency keys", deleted)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidCursor is returned for cursors that were not produced by Encode.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a position in a list ordered by (created_at, id), or by
+// (key, id) for lists sorted on another column. Order names that sort, so a
+// cursor cannot be replayed against a list ordered differently. Clients only
+// ever see it in its encoded, opaque form.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+	Key       string    `json:"k,omitempty"`
+	Order     string    `json:"o,omitempty"`
+}
+
+func NewCursor(createdAt time.Time, id uuid.UUID) *Cursor {
+	return &Cursor{
+		CreatedAt: createdAt,
+		ID:        id,
+	}
+}
+
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode parses an encoded cursor; an empty string means "from the start" and
+// yields nil.
+func Decode(encoded string) (*Cursor, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
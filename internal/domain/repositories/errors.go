@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
+	"gorm.io/gorm"
+)
+
+// Postgres SQLSTATE codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgNotNullViolation    = "23502"
+	pgCheckViolation      = "23514"
+)
+
+// translateError maps gorm and Postgres errors onto apperrors kinds, using
+// resource (e.g. "user") in the client-facing message. Errors it does not
+// recognise are returned unchanged and end up as internal errors.
+func translateError(err error, resource string) error {
+	if err == nil {
+		return nil
+	}
+
+	var appErr *apperrors.Error
+	if errors.As(err, &appErr) {
+		return err
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperrors.New(apperrors.KindNotFound, resource+" not found", err)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == pgUniqueViolation:
+			return apperrors.New(apperrors.KindConflict, resource+" already exists", err)
+		case pgErr.Code == pgForeignKeyViolation:
+			return apperrors.New(apperrors.KindConflict, resource+" is referenced by other records", err)
+		case pgErr.Code == pgNotNullViolation, pgErr.Code == pgCheckViolation,
+			strings.HasPrefix(pgErr.Code, "22"): // data exception
+			return apperrors.New(apperrors.KindValidation, "invalid "+resource, err)
+		case strings.HasPrefix(pgErr.Code, "08"), // connection exception
+			strings.HasPrefix(pgErr.Code, "53"),  // insufficient resources
+			strings.HasPrefix(pgErr.Code, "57P"): // server shutting down
+			return apperrors.Unavailable("database unavailable", err)
+		}
+		return err
+	}
+
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	if errors.As(err, &connectErr) || errors.As(err, &netErr) ||
+		errors.Is(err, driver.ErrBadConn) || errors.Is(err, context.DeadlineExceeded) ||
+		pgconn.Timeout(err) {
+		return apperrors.Unavailable("database unavailable", err)
+	}
+
+	return err
+}
@@ -1,17 +1,43 @@
 package repositories
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"github.com/kitamersion/go-goservice/internal/domain/pagination"
 	"gorm.io/gorm"
 )
 
 type EventRepository interface {
 	Create(event *entities.Event) error
+	CreateBatch(events []*entities.Event) error
 	GetByID(id uuid.UUID) (*entities.Event, error)
 	GetByType(eventType string, limit, offset int) ([]*entities.Event, error)
 	List(limit, offset int) ([]*entities.Event, error)
+	ListAfter(eventType string, after *pagination.Cursor, limit int) ([]*entities.Event, error)
 	Count() (int64, error)
+	CountByType(eventType string) (int64, error)
+	NextVersion(aggregateID string) (int64, error)
+	ListUnpublished(limit int) ([]*entities.Event, error)
+	MarkPublished(id uuid.UUID, publishedAt time.Time) error
+	MarkPublishedBatch(ids []uuid.UUID, publishedAt time.Time) error
+	RecordFailure(id uuid.UUID, reason string) error
+	MarkFailed(id uuid.UUID, reason string, failedAt time.Time) error
+	PendingStats() (*PendingStats, error)
+	WithTx(tx *gorm.DB) EventRepository
+}
+
+// createBatchSize caps the rows per INSERT statement, keeping the number of
+// bind parameters well below Postgres' limit.
+const createBatchSize = 500
+
+// PendingStats summarises the outbox backlog. Parked rows are not part of
+// Count and Oldest.
+type PendingStats struct {
+	Count  int64
+	Oldest *time.Time
+	Parked int64
 }
 
 type eventRepository struct {
@@ -28,6 +54,14 @@ func (r *eventRepository) Create(event *entities.Event) error {
 	return r.db.Create(event).Error
 }
 
+// CreateBatch appends several outbox rows with multi-row inserts.
+func (r *eventRepository) CreateBatch(events []*entities.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(events, createBatchSize).Error
+}
+
 func (r *eventRepository) GetByID(id uuid.UUID) (*entities.Event, error) {
 	var event entities.Event
 	err := r.db.Where("id = ?", id).First(&event).Error
@@ -37,6 +71,8 @@ func (r *eventRepository) GetByID(id uuid.UUID) (*entities.Event, error) {
 	return &event, nil
 }
 
+// GetByType and List page with LIMIT/OFFSET and are kept for backwards
+// compatibility; prefer the cursor-based variants.
 func (r *eventRepository) GetByType(eventType string, limit, offset int) ([]*entities.Event, error) {
 	var events []*entities.Event
 	err := r.db.Where("type = ?", eventType).
@@ -56,8 +92,120 @@ func (r *eventRepository) List(limit, offset int) ([]*entities.Event, error) {
 	return events, err
 }
 
+// ListAfter returns up to limit events ordered by (created_at, id), like user
+// listings, that come after the cursor (or the oldest ones when it is nil).
+// An empty eventType matches every type.
+func (r *eventRepository) ListAfter(eventType string, after *pagination.Cursor, limit int) ([]*entities.Event, error) {
+	query := r.ofType(eventType).Order("created_at ASC, id ASC").Limit(limit)
+	if after != nil {
+		query = query.Where("(created_at, id) > (?, ?)", after.CreatedAt, after.ID)
+	}
+
+	var events []*entities.Event
+	err := query.Find(&events).Error
+	return events, translateError(err, "event")
+}
+
 func (r *eventRepository) Count() (int64, error) {
 	var count int64
 	err := r.db.Model(&entities.Event{}).Count(&count).Error
 	return count, err
 }
+
+// CountByType counts the events of a type, or all of them for an empty type.
+func (r *eventRepository) CountByType(eventType string) (int64, error) {
+	var count int64
+	err := r.ofType(eventType).Model(&entities.Event{}).Count(&count).Error
+	return count, translateError(err, "event")
+}
+
+func (r *eventRepository) ofType(eventType string) *gorm.DB {
+	if eventType == "" {
+		return r.db
+	}
+	return r.db.Where("type = ?", eventType)
+}
+
+// NextVersion returns the version for the next event of an aggregate. Callers
+// should hold a lock on the aggregate; the unique index on (aggregate_id,
+// aggregate_version) rejects concurrent writers that don't.
+func (r *eventRepository) NextVersion(aggregateID string) (int64, error) {
+	var version int64
+	err := r.db.Model(&entities.Event{}).
+		Select("COALESCE(MAX(aggregate_version), 0) + 1").
+		Where("aggregate_id = ?", aggregateID).
+		Scan(&version).Error
+	return version, err
+}
+
+// ListUnpublished returns outbox rows that have not been sent or parked yet,
+// oldest first.
+func (r *eventRepository) ListUnpublished(limit int) ([]*entities.Event, error) {
+	var events []*entities.Event
+	err := r.db.Where("published_at IS NULL AND failed_at IS NULL").
+		Order("created_at ASC, id ASC").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+func (r *eventRepository) MarkPublished(id uuid.UUID, publishedAt time.Time) error {
+	return r.db.Model(&entities.Event{}).
+		Where("id = ?", id).
+		Update("published_at", publishedAt).Error
+}
+
+func (r *eventRepository) MarkPublishedBatch(ids []uuid.UUID, publishedAt time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Model(&entities.Event{}).
+		Where("id IN ?", ids).
+		Update("published_at", publishedAt).Error
+}
+
+// RecordFailure keeps track of delivery attempts for rows the relay could not publish.
+func (r *eventRepository) RecordFailure(id uuid.UUID, reason string) error {
+	return r.db.Model(&entities.Event{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":   gorm.Expr("attempts + 1"),
+			"last_error": reason,
+		}).Error
+}
+
+// MarkFailed records the last attempt and parks the row, so the relay stops
+// retrying it and moves on to later events.
+func (r *eventRepository) MarkFailed(id uuid.UUID, reason string, failedAt time.Time) error {
+	return r.db.Model(&entities.Event{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":   gorm.Expr("attempts + 1"),
+			"last_error": reason,
+			"failed_at":  failedAt,
+		}).Error
+}
+
+func (r *eventRepository) PendingStats() (*PendingStats, error) {
+	var row struct {
+		Count  int64
+		Oldest *time.Time
+		Parked int64
+	}
+	err := r.db.Model(&entities.Event{}).
+		Select("COUNT(*) FILTER (WHERE failed_at IS NULL) AS count, " +
+			"MIN(created_at) FILTER (WHERE failed_at IS NULL) AS oldest, " +
+			"COUNT(*) FILTER (WHERE failed_at IS NOT NULL) AS parked").
+		Where("published_at IS NULL").
+		Scan(&row).Error
+	if err != nil {
+		return nil, err
+	}
+	return &PendingStats{Count: row.Count, Oldest: row.Oldest, Parked: row.Parked}, nil
+}
+
+func (r *eventRepository) WithTx(tx *gorm.DB) EventRepository {
+	return &eventRepository{
+		db: tx,
+	}
+}
@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository interface {
+	// Reserve claims the key and reports false if someone else holds it. A key
+	// that was claimed before staleBefore and never completed is taken over.
+	Reserve(key *entities.IdempotencyKey, staleBefore time.Time) (bool, error)
+	Get(scope, key string) (*entities.IdempotencyKey, error)
+	// Complete stores the response of a claimed key.
+	Complete(key *entities.IdempotencyKey) error
+	// Release gives up a claimed key that has no response stored.
+	Release(scope, key string) error
+	DeleteOlderThan(cutoff time.Time) (int64, error)
+	WithTx(tx *gorm.DB) IdempotencyRepository
+}
+
+type idempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{
+		db: db,
+	}
+}
+
+func (r *idempotencyRepository) Reserve(key *entities.IdempotencyKey, staleBefore time.Time) (bool, error) {
+	// A claim has no status code until its response is stored
+	result := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "scope"}, {Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"request_hash", "created_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "idempotency_keys.status_code = 0 AND idempotency_keys.created_at < ?", Vars: []any{staleBefore}},
+		}},
+	}).Create(key)
+	if result.Error != nil {
+		return false, translateError(result.Error, "idempotency key")
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *idempotencyRepository) Get(scope, key string) (*entities.IdempotencyKey, error) {
+	var record entities.IdempotencyKey
+	err := r.db.Where("scope = ? AND key = ?", scope, key).First(&record).Error
+	if err != nil {
+		return nil, translateError(err, "idempotency key")
+	}
+	return &record, nil
+}
+
+func (r *idempotencyRepository) Complete(key *entities.IdempotencyKey) error {
+	// Only the claim this request holds; a retry may have taken over a stale one
+	result := r.db.Model(&entities.IdempotencyKey{}).
+		Where("scope = ? AND key = ? AND request_hash = ? AND status_code = 0", key.Scope, key.Key, key.RequestHash).
+		Select("status_code", "headers", "body").
+		Updates(key)
+	if result.Error != nil {
+		return translateError(result.Error, "idempotency key")
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("idempotency key %q is no longer claimed", key.Key)
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) Release(scope, key string) error {
+	err := r.db.Where("scope = ? AND key = ? AND status_code = 0", scope, key).Delete(&entities.IdempotencyKey{}).Error
+	return translateError(err, "idempotency key")
+}
+
+func (r *idempotencyRepository) DeleteOlderThan(cutoff time.Time) (int64, error) {
+	result := r.db.Where("created_at < ?", cutoff).Delete(&entities.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *idempotencyRepository) WithTx(tx *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{
+		db: tx,
+	}
+}
@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"github.com/google/uuid"
+	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"gorm.io/gorm"
+)
+
+type ImportJobRepository interface {
+	Create(job *entities.ImportJob) error
+	GetByID(id uuid.UUID) (*entities.ImportJob, error)
+	Update(job *entities.ImportJob) error
+	WithTx(tx *gorm.DB) ImportJobRepository
+}
+
+type importJobRepository struct {
+	db *gorm.DB
+}
+
+func NewImportJobRepository(db *gorm.DB) ImportJobRepository {
+	return &importJobRepository{
+		db: db,
+	}
+}
+
+func (r *importJobRepository) Create(job *entities.ImportJob) error {
+	return translateError(r.db.Create(job).Error, "job")
+}
+
+func (r *importJobRepository) GetByID(id uuid.UUID) (*entities.ImportJob, error) {
+	var job entities.ImportJob
+	err := r.db.Where("id = ?", id).First(&job).Error
+	if err != nil {
+		return nil, translateError(err, "job")
+	}
+	return &job, nil
+}
+
+func (r *importJobRepository) Update(job *entities.ImportJob) error {
+	return translateError(r.db.Save(job).Error, "job")
+}
+
+func (r *importJobRepository) WithTx(tx *gorm.DB) ImportJobRepository {
+	return &importJobRepository{
+		db: tx,
+	}
+}
@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ProcessedEventRepository interface {
+	// Record stores the event for the consumer group and reports false if it
+	// had already been recorded.
+	Record(eventID, consumerGroup string, processedAt time.Time) (bool, error)
+	DeleteOlderThan(cutoff time.Time) (int64, error)
+	WithTx(tx *gorm.DB) ProcessedEventRepository
+}
+
+type processedEventRepository struct {
+	db *gorm.DB
+}
+
+func NewProcessedEventRepository(db *gorm.DB) ProcessedEventRepository {
+	return &processedEventRepository{
+		db: db,
+	}
+}
+
+func (r *processedEventRepository) Record(eventID, consumerGroup string, processedAt time.Time) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&entities.ProcessedEvent{
+		EventID:       eventID,
+		ConsumerGroup: consumerGroup,
+		ProcessedAt:   processedAt,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *processedEventRepository) DeleteOlderThan(cutoff time.Time) (int64, error) {
+	result := r.db.Where("processed_at < ?", cutoff).Delete(&entities.ProcessedEvent{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *processedEventRepository) WithTx(tx *gorm.DB) ProcessedEventRepository {
+	return &processedEventRepository{
+		db: tx,
+	}
+}
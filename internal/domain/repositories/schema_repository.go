@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"gorm.io/gorm"
+)
+
+type SchemaRepository interface {
+	List(subject string) ([]*entities.SchemaVersion, error)
+	GetByFingerprint(subject, fingerprint string) (*entities.SchemaVersion, error)
+	Create(schema *entities.SchemaVersion) error
+}
+
+type schemaRepository struct {
+	db *gorm.DB
+}
+
+func NewSchemaRepository(db *gorm.DB) SchemaRepository {
+	return &schemaRepository{
+		db: db,
+	}
+}
+
+// List returns all versions of a subject, oldest first.
+func (r *schemaRepository) List(subject string) ([]*entities.SchemaVersion, error) {
+	var schemas []*entities.SchemaVersion
+	err := r.db.Where("subject = ?", subject).
+		Order("version ASC").
+		Find(&schemas).Error
+	return schemas, err
+}
+
+// GetByFingerprint returns nil when no version of the subject matches.
+func (r *schemaRepository) GetByFingerprint(subject, fingerprint string) (*entities.SchemaVersion, error) {
+	var schema entities.SchemaVersion
+	err := r.db.Where("subject = ? AND fingerprint = ?", subject, fingerprint).
+		Order("version DESC").
+		First(&schema).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// Create assigns the next version of the subject and a new schema ID.
+func (r *schemaRepository) Create(schema *entities.SchemaVersion) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var latest int
+		err := tx.Model(&entities.SchemaVersion{}).
+			Select("COALESCE(MAX(version), 0)").
+			Where("subject = ?", schema.Subject).
+			Scan(&latest).Error
+		if err != nil {
+			return err
+		}
+		schema.Version = latest + 1
+		return tx.Create(schema).Error
+	})
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/kitamersion/go-goservice/internal/database"
+	"gorm.io/gorm"
+)
+
+// Repositories groups the repositories that can take part in a transaction.
+type Repositories struct {
+	Users  UserRepository
+	Events EventRepository
+}
+
+// Transactor runs a unit of work inside a single database transaction.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(repos Repositories) error) error
+}
+
+type transactor struct {
+	db     *gorm.DB
+	users  UserRepository
+	events EventRepository
+}
+
+func NewTransactor(db *gorm.DB, users UserRepository, events EventRepository) Transactor {
+	return &transactor{
+		db:     db,
+		users:  users,
+		events: events,
+	}
+}
+
+// WithinTransaction commits when fn returns nil and rolls back otherwise. When
+// ctx already carries a transaction (see database.ContextWithTx) the work runs
+// in a savepoint of that transaction instead. Database errors that fn did not
+// translate itself, including commit failures, come back as apperrors kinds.
+func (t *transactor) WithinTransaction(ctx context.Context, fn func(repos Repositories) error) error {
+	db := t.db
+	if tx, ok := database.TxFromContext(ctx); ok {
+		db = tx
+	}
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(Repositories{
+			Users:  t.users.WithTx(tx),
+			Events: t.events.WithTx(tx),
+		})
+	})
+	return translateError(err, "record")
+}
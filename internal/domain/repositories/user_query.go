@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
+	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"github.com/kitamersion/go-goservice/internal/domain/pagination"
+	"gorm.io/gorm"
+)
+
+// ErrCursorOrder is returned for a cursor taken from a listing with another
+// order than the one requested.
+var ErrCursorOrder = errors.New("cursor was issued for a different order")
+
+// UserFilter narrows a user listing. Zero fields do not filter, and all set
+// fields must match. Values are used as bind parameters, never as SQL.
+type UserFilter struct {
+	EmailDomain   string     // exact domain after the "@", lower case
+	NamePrefix    string     // case-insensitive
+	CreatedAfter  *time.Time // inclusive
+	CreatedBefore *time.Time // exclusive
+	Search        string     // every word must occur in the name or email, case-insensitive
+}
+
+type UserSortField string
+
+const (
+	UserSortCreatedAt UserSortField = "created_at"
+	UserSortName      UserSortField = "name"
+)
+
+// UserSort orders a user listing; ties are broken by ID so that the order is
+// total and can be paged with a cursor. The zero value is oldest first.
+type UserSort struct {
+	Field      UserSortField
+	Descending bool
+}
+
+// ParseUserSort parses "field" or "-field" (descending), e.g. "-created_at".
+func ParseUserSort(s string) (UserSort, bool) {
+	field, descending := strings.CutPrefix(s, "-")
+	switch UserSortField(field) {
+	case UserSortCreatedAt, UserSortName:
+		return UserSort{Field: UserSortField(field), Descending: descending}, true
+	}
+	return UserSort{}, false
+}
+
+func (s UserSort) String() string {
+	field := s.Field
+	if field == "" {
+		field = UserSortCreatedAt
+	}
+	if s.Descending {
+		return "-" + string(field)
+	}
+	return string(field)
+}
+
+// Cursor returns the position of user in a listing with this order.
+func (s UserSort) Cursor(user *entities.UserEntity) *pagination.Cursor {
+	cursor := pagination.NewCursor(user.CreatedAt, user.ID)
+	if s.Field == UserSortName {
+		cursor.Key = user.Name
+	}
+	cursor.Order = s.cursorOrder()
+	return cursor
+}
+
+// cursorOrder is the order recorded in cursors. It is empty for oldest first,
+// the only order cursors had before sorting existed, so those stay valid.
+func (s UserSort) cursorOrder() string {
+	if order := s.String(); order != string(UserSortCreatedAt) {
+		return order
+	}
+	return ""
+}
+
+// UserQuery combines a filter and an order for listing users.
+type UserQuery struct {
+	Filter UserFilter
+	Sort   UserSort
+}
+
+// apply adds the WHERE clauses of the filter. Name and email matches use
+// ILIKE/LIKE patterns, which the trigram indexes created by
+// database.RunMigrations serve.
+func (f UserFilter) apply(db *gorm.DB) *gorm.DB {
+	if f.EmailDomain != "" {
+		db = db.Where("email LIKE ?", "%@"+escapeLike(f.EmailDomain))
+	}
+	if f.NamePrefix != "" {
+		db = db.Where("name ILIKE ?", escapeLike(f.NamePrefix)+"%")
+	}
+	if f.CreatedAfter != nil {
+		db = db.Where("created_at >= ?", *f.CreatedAfter)
+	}
+	if f.CreatedBefore != nil {
+		db = db.Where("created_at < ?", *f.CreatedBefore)
+	}
+	for _, word := range strings.Fields(f.Search) {
+		pattern := "%" + escapeLike(word) + "%"
+		db = db.Where("(name ILIKE ? OR email ILIKE ?)", pattern, pattern)
+	}
+	return db
+}
+
+// order adds the ORDER BY clause and, when after is set, the keyset condition
+// selecting the rows following it.
+func (s UserSort) order(db *gorm.DB, after *pagination.Cursor) (*gorm.DB, error) {
+	column := "created_at"
+	if s.Field == UserSortName {
+		column = "name"
+	}
+	direction, comparison := "ASC", ">"
+	if s.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	db = db.Order(column + " " + direction + ", id " + direction)
+
+	if after == nil {
+		return db, nil
+	}
+	if after.Order != s.cursorOrder() {
+		return nil, apperrors.New(apperrors.KindValidation, "cursor does not match the requested sort order", ErrCursorOrder)
+	}
+	var key interface{} = after.CreatedAt
+	if s.Field == UserSortName {
+		key = after.Key
+	}
+	return db.Where("("+column+", id) "+comparison+" (?, ?)", key, after.ID), nil
+}
+
+// escapeLike makes s match literally inside a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
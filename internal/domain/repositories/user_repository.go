@@ -1,19 +1,35 @@
 package repositories
 
 import (
+	"errors"
+	"strings"
+
 	"github.com/google/uuid"
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
 	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"github.com/kitamersion/go-goservice/internal/domain/pagination"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrVersionConflict reports an update based on an outdated version of a row.
+var ErrVersionConflict = errors.New("version conflict")
+
 type UserRepository interface {
 	Create(user *entities.UserEntity) error
+	CreateBatch(users []*entities.UserEntity) ([]*entities.UserEntity, error)
 	GetByID(id uuid.UUID) (*entities.UserEntity, error)
+	// GetByIDForUpdate loads the user and locks its row until the surrounding
+	// transaction ends, so it cannot change between the read and an Update.
+	GetByIDForUpdate(id uuid.UUID) (*entities.UserEntity, error)
+	GetByIDs(ids []uuid.UUID) ([]*entities.UserEntity, error)
 	GetByEmail(email string) (*entities.UserEntity, error)
 	Update(user *entities.UserEntity) error
 	Delete(id uuid.UUID) error
-	List(limit, offset int) ([]*entities.UserEntity, error)
-	Count() (int64, error)
+	List(query UserQuery, limit, offset int) ([]*entities.UserEntity, error)
+	ListAfter(query UserQuery, after *pagination.Cursor, limit int) ([]*entities.UserEntity, error)
+	Count(filter UserFilter) (int64, error)
+	WithTx(tx *gorm.DB) UserRepository
 }
 
 type userRepository struct {
@@ -26,44 +42,174 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	}
 }
 
+// Errors are translated into apperrors kinds, e.g. a duplicate email is a
+// conflict and a missing row is not found.
 func (r *userRepository) Create(user *entities.UserEntity) error {
-	return r.db.Create(user).Error
+	return translateError(r.db.Create(user).Error, "user")
+}
+
+// CreateBatch inserts the users in one statement, skipping those whose email
+// is already taken (including duplicates within the batch), and returns the
+// users that were inserted. IDs must be assigned by the caller.
+func (r *userRepository) CreateBatch(users []*entities.UserEntity) ([]*entities.UserEntity, error) {
+	if len(users) == 0 {
+		return nil, nil
+	}
+
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(&entities.UserEntity{}); err != nil {
+		return nil, err
+	}
+
+	// A raw INSERT ... RETURNING tells exactly which rows were skipped; gorm's
+	// Create would map the returned rows onto the wrong elements
+	values := make([]string, 0, len(users))
+	args := make([]interface{}, 0, len(users)*6)
+	for _, user := range users {
+		values = append(values, "(?, ?, ?, ?, ?, ?)")
+		args = append(args, user.ID, user.Email, user.Name, user.CreatedAt, user.UpdatedAt, user.Version)
+	}
+	query := "INSERT INTO " + stmt.Quote(stmt.Schema.Table) +
+		" (id, email, name, created_at, updated_at, version) VALUES " + strings.Join(values, ", ") +
+		" ON CONFLICT (email) DO NOTHING RETURNING id"
+
+	var ids []uuid.UUID
+	if err := r.db.Raw(query, args...).Scan(&ids).Error; err != nil {
+		return nil, translateError(err, "user")
+	}
+
+	inserted := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		inserted[id] = true
+	}
+	created := make([]*entities.UserEntity, 0, len(ids))
+	for _, user := range users {
+		if inserted[user.ID] {
+			created = append(created, user)
+		}
+	}
+	return created, nil
 }
 
 func (r *userRepository) GetByID(id uuid.UUID) (*entities.UserEntity, error) {
 	var user entities.UserEntity
 	err := r.db.Where("id = ?", id).First(&user).Error
 	if err != nil {
-		return nil, err
+		return nil, translateError(err, "user")
+	}
+	return &user, nil
+}
+
+func (r *userRepository) GetByIDForUpdate(id uuid.UUID) (*entities.UserEntity, error) {
+	var user entities.UserEntity
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&user).Error
+	if err != nil {
+		return nil, translateError(err, "user")
 	}
 	return &user, nil
 }
 
+// GetByIDs loads the users with the given IDs in one query. Unknown IDs are
+// skipped, and the users come back in no particular order.
+func (r *userRepository) GetByIDs(ids []uuid.UUID) ([]*entities.UserEntity, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var users []*entities.UserEntity
+	err := r.db.Where("id IN ?", ids).Find(&users).Error
+	return users, translateError(err, "user")
+}
+
 func (r *userRepository) GetByEmail(email string) (*entities.UserEntity, error) {
 	var user entities.UserEntity
 	err := r.db.Where("email = ?", email).First(&user).Error
 	if err != nil {
-		return nil, err
+		return nil, translateError(err, "user")
 	}
 	return &user, nil
 }
 
+// Update writes the user only if its stored version still equals
+// user.Version, then bumps the version. A stale version yields a conflict
+// wrapping ErrVersionConflict.
 func (r *userRepository) Update(user *entities.UserEntity) error {
-	return r.db.Save(user).Error
+	result := r.db.Model(&entities.UserEntity{}).
+		Where("id = ? AND version = ?", user.ID, user.Version).
+		Updates(map[string]interface{}{
+			"name":       user.Name,
+			"email":      user.Email,
+			"updated_at": user.UpdatedAt,
+			"version":    gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return translateError(result.Error, "user")
+	}
+
+	if result.RowsAffected == 0 {
+		// Either the user is gone or someone else updated it first
+		var count int64
+		if err := r.db.Model(&entities.UserEntity{}).Where("id = ?", user.ID).Count(&count).Error; err != nil {
+			return translateError(err, "user")
+		}
+		if count == 0 {
+			return translateError(gorm.ErrRecordNotFound, "user")
+		}
+		return apperrors.New(apperrors.KindConflict, "user was modified by another request", ErrVersionConflict)
+	}
+
+	user.Version++
+	return nil
 }
 
+// Delete returns a not found error when no user has the given ID.
 func (r *userRepository) Delete(id uuid.UUID) error {
-	return r.db.Delete(&entities.UserEntity{}, "id = ?", id).Error
+	result := r.db.Delete(&entities.UserEntity{}, "id = ?", id)
+	if result.Error != nil {
+		return translateError(result.Error, "user")
+	}
+	if result.RowsAffected == 0 {
+		return translateError(gorm.ErrRecordNotFound, "user")
+	}
+	return nil
+}
+
+// List pages with LIMIT/OFFSET and is kept for backwards compatibility; prefer
+// ListAfter.
+func (r *userRepository) List(query UserQuery, limit, offset int) ([]*entities.UserEntity, error) {
+	db, err := query.Sort.order(query.Filter.apply(r.db), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []*entities.UserEntity
+	err = db.Limit(limit).Offset(offset).Find(&users).Error
+	return users, translateError(err, "user")
 }
 
-func (r *userRepository) List(limit, offset int) ([]*entities.UserEntity, error) {
+// ListAfter returns up to limit users matching the query in its order,
+// starting after the cursor (or from the beginning when it is nil). A cursor
+// issued for another order is a validation error.
+func (r *userRepository) ListAfter(query UserQuery, after *pagination.Cursor, limit int) ([]*entities.UserEntity, error) {
+	db, err := query.Sort.order(query.Filter.apply(r.db), after)
+	if err != nil {
+		return nil, err
+	}
+
 	var users []*entities.UserEntity
-	err := r.db.Limit(limit).Offset(offset).Find(&users).Error
-	return users, err
+	err = db.Limit(limit).Find(&users).Error
+	return users, translateError(err, "user")
 }
 
-func (r *userRepository) Count() (int64, error) {
+// Count returns the number of users matching the filter.
+func (r *userRepository) Count(filter UserFilter) (int64, error) {
 	var count int64
-	err := r.db.Model(&entities.UserEntity{}).Count(&count).Error
-	return count, err
+	err := filter.apply(r.db.Model(&entities.UserEntity{})).Count(&count).Error
+	return count, translateError(err, "user")
+}
+
+func (r *userRepository) WithTx(tx *gorm.DB) UserRepository {
+	return &userRepository{
+		db: tx,
+	}
 }
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
+	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"github.com/kitamersion/go-goservice/internal/domain/pagination"
+	"github.com/kitamersion/go-goservice/internal/domain/repositories"
+)
+
+// EventPage is one page of a cursor-paginated event listing. Total counts every
+// event of the requested type, not just this page.
+type EventPage struct {
+	Events     []*entities.Event
+	NextCursor string // empty on the last page
+	Total      int64
+}
+
+// EventService reads the recorded domain events, oldest first.
+type EventService struct {
+	eventRepo repositories.EventRepository
+}
+
+func NewEventService(eventRepo repositories.EventRepository) *EventService {
+	return &EventService{
+		eventRepo: eventRepo,
+	}
+}
+
+// ListEventsAfter returns the page of events of eventType (all types when
+// empty) that follows the opaque cursor; an empty cursor starts at the
+// beginning.
+func (s *EventService) ListEventsAfter(eventType, cursor string, limit int) (*EventPage, error) {
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return nil, apperrors.New(apperrors.KindValidation, "invalid cursor", err)
+	}
+
+	// Fetch one extra row to know whether another page follows
+	events, err := s.eventRepo.ListAfter(eventType, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.eventRepo.CountByType(eventType)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &EventPage{Events: events, Total: total}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		page.NextCursor = EventCursor(page.Events[limit-1])
+	}
+	return page, nil
+}
+
+// EventCursor returns the opaque cursor positioned at event.
+func EventCursor(event *entities.Event) string {
+	return pagination.NewCursor(event.CreatedAt, event.ID).Encode()
+}
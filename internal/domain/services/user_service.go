@@ -2,84 +2,292 @@ package services
 
 import (
 	"context"
-	"errors"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
 	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"github.com/kitamersion/go-goservice/internal/domain/pagination"
 	"github.com/kitamersion/go-goservice/internal/domain/repositories"
-	"github.com/kitamersion/go-goservice/internal/events/producer"
+	"github.com/kitamersion/go-goservice/internal/events/outbox"
 	"github.com/kitamersion/go-goservice/internal/events/proto/events/userpb"
+	"google.golang.org/protobuf/proto"
 )
 
+// UserPatch holds the fields of a partial update; nil fields are left as they
+// are. When ExpectedVersions is not nil the patch is rejected with a conflict
+// unless the stored user has one of those versions; an empty, non-nil list
+// never matches.
+type UserPatch struct {
+	Name             *string
+	Email            *string
+	ExpectedVersions []int64
+}
+
+// UserPage is one page of a cursor-paginated user listing. Total counts every
+// user matching the filter, not just this page.
+type UserPage struct {
+	Users      []*entities.UserEntity
+	NextCursor string // empty on the last page
+	Total      int64
+	sort       repositories.UserSort
+}
+
+// Cursor returns the opaque cursor positioned at user, one of the page's users.
+func (p *UserPage) Cursor(user *entities.UserEntity) string {
+	return p.sort.Cursor(user).Encode()
+}
+
 type UserService struct {
-	userRepo repositories.UserRepository
-	producer *producer.Producer
+	userRepo   repositories.UserRepository
+	transactor repositories.Transactor
 }
 
-func NewUserService(userRepo repositories.UserRepository, producer *producer.Producer) *UserService {
+func NewUserService(userRepo repositories.UserRepository, transactor repositories.Transactor) *UserService {
 	return &UserService{
-		userRepo: userRepo,
-		producer: producer,
+		userRepo:   userRepo,
+		transactor: transactor,
 	}
 }
 
-func (s *UserService) CreateUser(ctx context.Context, user *entities.UserEntity) (uuid.UUID, error) {
+// CreateUser stores the user and its UserCreated outbox event atomically; the
+// outbox relay takes care of publishing. Only Name and Email are taken from
+// user; the stored entity is returned.
+func (s *UserService) CreateUser(ctx context.Context, user *entities.UserEntity) (*entities.UserEntity, error) {
 	entity := &entities.UserEntity{
-		ID:        uuid.New(),
-		Name:      user.Name,
-		Email:     user.Email,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-	if err := s.userRepo.Create(entity); err != nil {
-		return uuid.UUID{}, err
+		Name:  user.Name,
+		Email: user.Email,
 	}
-	event := &userpb.UserCreated{
-		Id:        entity.ID.String(),
-		Email:     entity.Email,
-		Name:      entity.Name,
-		CreatedAt: entity.CreatedAt.Unix(),
+	initNewUser(entity, time.Now())
+
+	err := s.transactor.WithinTransaction(ctx, func(repos repositories.Repositories) error {
+		if err := repos.Users.Create(entity); err != nil {
+			return err
+		}
+		return recordEvent(repos, entity.ID, userCreatedEvent(entity))
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if err := s.producer.PublishEvent(ctx, event); err != nil {
-		return uuid.UUID{}, err
+	return entity, nil
+}
+
+// CreateUsers inserts a batch of users and their UserCreated outbox events in
+// one transaction, assigning IDs and timestamps to the given users. Users whose
+// email is already taken are skipped; the ones that were created are returned.
+func (s *UserService) CreateUsers(ctx context.Context, users []*entities.UserEntity) ([]*entities.UserEntity, error) {
+	now := time.Now()
+	for _, user := range users {
+		initNewUser(user, now)
 	}
 
-	return entity.ID, nil
+	var created []*entities.UserEntity
+	err := s.transactor.WithinTransaction(ctx, func(repos repositories.Repositories) error {
+		var err error
+		created, err = repos.Users.CreateBatch(users)
+		if err != nil {
+			return err
+		}
+
+		events := make([]*entities.Event, 0, len(created))
+		for _, user := range created {
+			event, err := outbox.NewEvent(user.ID.String(), userCreatedEvent(user))
+			if err != nil {
+				return err
+			}
+			// The users are new, so this is the first event of each aggregate
+			event.AggregateVersion = 1
+			events = append(events, event)
+		}
+		return repos.Events.CreateBatch(events)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
 }
 
+// GetUserByID fails with an apperrors.NotFound error when the user does not
+// exist.
 func (s *UserService) GetUserByID(id uuid.UUID) (*entities.UserEntity, error) {
-	user, err := s.userRepo.GetByID(id)
+	return s.userRepo.GetByID(id)
+}
+
+// GetUsersByIDs looks up many users with a single query, keyed by ID. IDs
+// without a user are missing from the result.
+func (s *UserService) GetUsersByIDs(ids []uuid.UUID) (map[uuid.UUID]*entities.UserEntity, error) {
+	users, err := s.userRepo.GetByIDs(ids)
 	if err != nil {
 		return nil, err
 	}
-	if user == nil {
-		return nil, errors.New("user not found")
+	byID := make(map[uuid.UUID]*entities.UserEntity, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
 	}
-	return user, nil
+	return byID, nil
 }
 
-func (s *UserService) UpdateUser(ctx context.Context, user *entities.UserEntity) error {
-	if err := s.userRepo.Update(user); err != nil {
-		return err
+// ListUsers returns a page of the users matching the query together with the
+// number of matching users.
+func (s *UserService) ListUsers(query repositories.UserQuery, limit, offset int) ([]*entities.UserEntity, int64, error) {
+	users, err := s.userRepo.List(query, limit, offset)
+	if err != nil {
+		return nil, 0, err
 	}
-	event := &userpb.UserUpdated{
-		Id:        user.ID.String(),
-		UpdatedAt: user.UpdatedAt.Unix(),
+	total, err := s.userRepo.Count(query.Filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// ListUsersAfter returns the page of users matching the query that follows the
+// opaque cursor; an empty cursor starts at the beginning.
+func (s *UserService) ListUsersAfter(query repositories.UserQuery, cursor string, limit int) (*UserPage, error) {
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return nil, apperrors.New(apperrors.KindValidation, "invalid cursor", err)
+	}
+
+	// Fetch one extra row to know whether another page follows
+	users, err := s.userRepo.ListAfter(query, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.userRepo.Count(query.Filter)
+	if err != nil {
+		return nil, err
 	}
 
-	return s.producer.PublishEvent(ctx, event)
+	page := &UserPage{Users: users, Total: total, sort: query.Sort}
+	if len(users) > limit {
+		page.Users = users[:limit]
+		page.NextCursor = page.Cursor(page.Users[limit-1])
+	}
+	return page, nil
 }
 
-func (s *UserService) DeleteUser(ctx context.Context, id uuid.UUID) error {
-	if err := s.userRepo.Delete(id); err != nil {
-		return err
+// ReplaceUser sets every writable field of the user. As with PatchUser, the
+// read and the write share a transaction, and nothing is written or recorded
+// when the fields already hold these values.
+func (s *UserService) ReplaceUser(ctx context.Context, id uuid.UUID, name, email string, expectedVersions []int64) (*entities.UserEntity, error) {
+	return s.PatchUser(ctx, id, UserPatch{
+		Name:             &name,
+		Email:            &email,
+		ExpectedVersions: expectedVersions,
+	})
+}
+
+// PatchUser applies a partial update. UserUpdated is only recorded when a field
+// actually changed; otherwise the stored user is returned untouched. The row is
+// locked while the patch is applied, so an update without ExpectedVersions never
+// fails because of a concurrent one.
+func (s *UserService) PatchUser(ctx context.Context, id uuid.UUID, patch UserPatch) (*entities.UserEntity, error) {
+	var user *entities.UserEntity
+	err := s.transactor.WithinTransaction(ctx, func(repos repositories.Repositories) error {
+		var err error
+		user, err = repos.Users.GetByIDForUpdate(id)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(user, patch.ExpectedVersions); err != nil {
+			return err
+		}
+
+		changed := false
+		if patch.Name != nil && *patch.Name != user.Name {
+			user.Name = *patch.Name
+			changed = true
+		}
+		if patch.Email != nil && *patch.Email != user.Email {
+			user.Email = *patch.Email
+			changed = true
+		}
+		if !changed {
+			return nil
+		}
+
+		user.UpdatedAt = time.Now()
+		if err := repos.Users.Update(user); err != nil {
+			return err
+		}
+		return recordEvent(repos, user.ID, &userpb.UserUpdated{
+			Id:        user.ID.String(),
+			UpdatedAt: user.UpdatedAt.Unix(),
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
+	return user, nil
+}
+
+// DeleteUser removes the user. When expectedVersions is not nil the user is
+// only deleted if it has one of those versions, as with UserPatch.
+func (s *UserService) DeleteUser(ctx context.Context, id uuid.UUID, expectedVersions []int64) error {
 	event := &userpb.UserDeleted{
 		Id:        id.String(),
 		DeletedAt: time.Now().Unix(),
 	}
 
-	return s.producer.PublishEvent(ctx, event)
+	return s.transactor.WithinTransaction(ctx, func(repos repositories.Repositories) error {
+		if expectedVersions != nil {
+			user, err := repos.Users.GetByIDForUpdate(id)
+			if err != nil {
+				return err
+			}
+			if err := checkVersion(user, expectedVersions); err != nil {
+				return err
+			}
+		}
+		if err := repos.Users.Delete(id); err != nil {
+			return err
+		}
+		return recordEvent(repos, id, event)
+	})
+}
+
+// checkVersion returns a conflict wrapping repositories.ErrVersionConflict
+// unless expected is nil or holds the user's version.
+func checkVersion(user *entities.UserEntity, expected []int64) error {
+	if expected == nil || slices.Contains(expected, user.Version) {
+		return nil
+	}
+	return apperrors.New(apperrors.KindConflict, "user was modified by another request", repositories.ErrVersionConflict)
+}
+
+func initNewUser(user *entities.UserEntity, now time.Time) {
+	user.ID = uuid.New()
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	user.Version = 1
+}
+
+func userCreatedEvent(user *entities.UserEntity) *userpb.UserCreated {
+	return &userpb.UserCreated{
+		Id:        user.ID.String(),
+		Email:     user.Email,
+		Name:      user.Name,
+		CreatedAt: user.CreatedAt.Unix(),
+	}
+}
+
+// recordEvent appends an event to the outbox with the aggregate's next
+// version. It must run after the user row was written, so the row lock
+// serialises concurrent writers of the same user.
+func recordEvent(repos repositories.Repositories, userID uuid.UUID, event proto.Message) error {
+	outboxEvent, err := outbox.NewEvent(userID.String(), event)
+	if err != nil {
+		return err
+	}
+
+	version, err := repos.Events.NextVersion(outboxEvent.AggregateID)
+	if err != nil {
+		return err
+	}
+	outboxEvent.AggregateVersion = version
+
+	return repos.Events.Create(outboxEvent)
 }
@@ -0,0 +1,53 @@
+package codec
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Supported event payload encodings, carried in the content_type header.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// Normalize maps a configured or received content type onto one of the
+// supported encodings. Messages without a content type predate the header and
+// are JSON.
+func Normalize(contentType string) (string, error) {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch mediaType {
+	case "", ContentTypeJSON:
+		return ContentTypeJSON, nil
+	case ContentTypeProtobuf, "application/protobuf", "application/vnd.google.protobuf":
+		return ContentTypeProtobuf, nil
+	default:
+		return "", fmt.Errorf("unsupported content type %q", contentType)
+	}
+}
+
+func Marshal(contentType string, message proto.Message) ([]byte, error) {
+	normalized, err := Normalize(contentType)
+	if err != nil {
+		return nil, err
+	}
+	if normalized == ContentTypeProtobuf {
+		return proto.Marshal(message)
+	}
+	return protojson.Marshal(message)
+}
+
+func Unmarshal(contentType string, payload []byte, message proto.Message) error {
+	normalized, err := Normalize(contentType)
+	if err != nil {
+		return err
+	}
+	if normalized == ContentTypeProtobuf {
+		return proto.Unmarshal(payload, message)
+	}
+	// Fields added by newer producers must not break older consumers
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(payload, message)
+}
@@ -0,0 +1,107 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultCommitBatchSize = 100
+	defaultCommitInterval  = time.Second
+)
+
+// committer batches offset commits. Only messages that were fully processed
+// are handed to it, so a crash can at worst replay messages, never skip them.
+type committer struct {
+	reader    *kafka.Reader
+	logger    *logrus.Logger
+	batchSize int
+
+	mu      sync.Mutex
+	pending map[int]kafka.Message // partition -> highest processed message
+	count   int
+}
+
+func newCommitter(reader *kafka.Reader, batchSize int, logger *logrus.Logger) *committer {
+	if batchSize <= 0 {
+		batchSize = defaultCommitBatchSize
+	}
+	return &committer{
+		reader:    reader,
+		logger:    logger,
+		batchSize: batchSize,
+		pending:   make(map[int]kafka.Message),
+	}
+}
+
+// MarkDone records a processed message and commits once a full batch is pending.
+func (c *committer) MarkDone(ctx context.Context, message kafka.Message) error {
+	c.mu.Lock()
+	if current, ok := c.pending[message.Partition]; !ok || message.Offset > current.Offset {
+		c.pending[message.Partition] = message
+	}
+	c.count++
+	full := c.count >= c.batchSize
+	c.mu.Unlock()
+
+	if full {
+		return c.Flush(ctx)
+	}
+	return nil
+}
+
+// Flush commits everything processed so far.
+func (c *committer) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	if len(c.pending) == 0 {
+		c.mu.Unlock()
+		return nil
+	}
+	messages := make([]kafka.Message, 0, len(c.pending))
+	for _, message := range c.pending {
+		messages = append(messages, message)
+	}
+	c.pending = make(map[int]kafka.Message)
+	c.count = 0
+	c.mu.Unlock()
+
+	if err := c.reader.CommitMessages(ctx, messages...); err != nil {
+		// Put the offsets back so the next flush retries them
+		c.mu.Lock()
+		for _, message := range messages {
+			if current, ok := c.pending[message.Partition]; !ok || message.Offset > current.Offset {
+				c.pending[message.Partition] = message
+			}
+		}
+		c.mu.Unlock()
+		return fmt.Errorf("failed to commit offsets: %w", err)
+	}
+
+	c.logger.Debugf("Committed offsets for %d partitions", len(messages))
+	return nil
+}
+
+// Run flushes on every interval until ctx is cancelled.
+func (c *committer) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultCommitInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Flush(ctx); err != nil {
+				c.logger.WithError(err).Error("Failed to commit offsets")
+			}
+		}
+	}
+}
@@ -2,9 +2,13 @@ package consumer
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/kitamersion/go-goservice/internal/config"
+	"github.com/kitamersion/go-goservice/internal/events/dedup"
+	"github.com/kitamersion/go-goservice/internal/events/dlq"
+	"github.com/kitamersion/go-goservice/internal/events/types"
 	"github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
 )
@@ -12,82 +16,250 @@ import (
 // EventHandler handles proto messages with their raw JSON payload and headers
 type EventHandler func(ctx context.Context, eventType string, headers map[string]string, payload []byte) error
 
+// Option customises a Consumer.
+type Option func(*Consumer)
+
+// WithDedupStore skips events whose event_id was already processed and records
+// the ones that succeed.
+func WithDedupStore(store dedup.Store) Option {
+	return func(c *Consumer) {
+		c.dedup = store
+	}
+}
+
+// HandlerOption customises how a registered handler is invoked.
+type HandlerOption func(*registration)
+
+// WithRetryPolicy overrides the consumer-wide retry policy for one handler.
+func WithRetryPolicy(policy RetryPolicy) HandlerOption {
+	return func(r *registration) {
+		r.retry = policy
+	}
+}
+
+type registration struct {
+	handler EventHandler
+	retry   RetryPolicy
+}
+
 type Consumer struct {
-	reader   *kafka.Reader
-	logger   *logrus.Logger
-	handlers map[string]EventHandler // eventType -> handler mapping
+	reader         *kafka.Reader
+	committer      *committer
+	commitInterval time.Duration
+	concurrency    int
+	orderBy        string
+	dlq            *dlq.Writer
+	dedup          dedup.Store
+	sequences      *sequenceTracker
+	logger         *logrus.Logger
+	retry          RetryPolicy
+	handlers       map[string]registration // eventType -> handler mapping
 }
 
-func NewConsumer(cfg *config.KafkaConfig, logger *logrus.Logger) *Consumer {
+func NewConsumer(cfg *config.KafkaConfig, logger *logrus.Logger, opts ...Option) *Consumer {
 	readerConfig := kafka.ReaderConfig{
-		Brokers:        cfg.Brokers,
-		Topic:          cfg.Topics.UserEvents,
-		GroupID:        cfg.ConsumerGroups.UserConsumer,
-		StartOffset:    kafka.FirstOffset,
-		MinBytes:       1,           // 1B
-		MaxBytes:       10e6,        // 10MB
-		CommitInterval: time.Second, // flushes commits to Kafka every second
+		Brokers:     cfg.Brokers,
+		Topic:       cfg.Topics.UserEvents,
+		GroupID:     cfg.ConsumerGroups.UserConsumer,
+		StartOffset: kafka.FirstOffset,
+		MinBytes:    1,    // 1B
+		MaxBytes:    10e6, // 10MB
+		// CommitInterval is left at zero: offsets are committed explicitly,
+		// and only once the message was handled or dead-lettered
 	}
 	logger.Infof("Creating Kafka reader with config: Brokers=%v Topic=%s GroupID=%s", readerConfig.Brokers, readerConfig.Topic, readerConfig.GroupID)
 
 	reader := kafka.NewReader(readerConfig)
 
-	return &Consumer{
-		reader:   reader,
-		logger:   logger,
-		handlers: make(map[string]EventHandler),
+	var dlqWriter *dlq.Writer
+	if cfg.Topics.UserEventsDLQ != "" {
+		dlqWriter = dlq.NewWriter(cfg.Brokers, cfg.Topics.UserEventsDLQ, logger)
+	}
+
+	c := &Consumer{
+		reader:         reader,
+		committer:      newCommitter(reader, cfg.Consumer.CommitBatchSize, logger),
+		commitInterval: cfg.Consumer.CommitInterval,
+		concurrency:    cfg.Consumer.Concurrency,
+		orderBy:        cfg.Consumer.OrderBy,
+		dlq:            dlqWriter,
+		logger:         logger,
+		retry:          NewRetryPolicy(&cfg.Retry),
+		sequences:      newSequenceTracker(logger),
+		handlers:       make(map[string]registration),
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
-func (c *Consumer) RegisterHandler(eventType string, handler EventHandler) {
-	c.handlers[eventType] = handler
+func (c *Consumer) RegisterHandler(eventType string, handler EventHandler, opts ...HandlerOption) {
+	r := registration{
+		handler: handler,
+		retry:   c.retry,
+	}
+	for _, opt := range opts {
+		opt(&r)
+	}
+	c.handlers[eventType] = r
 }
 
+// Start consumes until ctx is cancelled or a message can neither be handled nor
+// dead-lettered. Pending offsets are flushed before it returns, so a restart
+// resumes right after the last processed message.
 func (c *Consumer) Start(ctx context.Context) error {
 	c.logger.Info("Starting Kafka consumer loop")
+
+	commitCtx, stopCommitter := context.WithCancel(context.Background())
+	go c.committer.Run(commitCtx, c.commitInterval)
+	defer func() {
+		stopCommitter()
+		c.flush()
+	}()
+
+	if c.concurrency > 1 {
+		return c.consumeConcurrently(ctx)
+	}
+	return c.consumeSequentially(ctx)
+}
+
+func (c *Consumer) consumeSequentially(ctx context.Context) error {
 	for {
 		select {
 		case <-ctx.Done():
 			c.logger.Info("Context cancelled, shutting down consumer")
 			return ctx.Err()
 		default:
-			c.logger.Debug("Waiting to read message...")
-			message, err := c.reader.ReadMessage(ctx)
+			c.logger.Debug("Waiting to fetch message...")
+			message, err := c.reader.FetchMessage(ctx)
 			if err != nil {
-				c.logger.WithError(err).Error("Failed to read message")
+				if ctx.Err() != nil {
+					continue
+				}
+				c.logger.WithError(err).Error("Failed to fetch message")
 				time.Sleep(time.Second) // backoff on error
 				continue
 			}
 
-			c.logger.Infof("Received message at topic %s partition %d offset %d", message.Topic, message.Partition, message.Offset)
-			c.logger.Debugf("Message key: %s, value: %s", string(message.Key), string(message.Value))
+			if err := c.process(ctx, message); err != nil {
+				// Leave the offset uncommitted so the message is redelivered
+				return err
+			}
 
-			// Extract headers from Kafka message
-			headers := make(map[string]string)
-			eventType := ""
-			for _, header := range message.Headers {
-				headers[header.Key] = string(header.Value)
-				if header.Key == "event_type" {
-					eventType = string(header.Value)
-				}
+			if err := c.committer.MarkDone(ctx, message); err != nil {
+				c.logger.WithError(err).Error("Failed to commit offsets")
 			}
+		}
+	}
+}
 
-			c.logger.Infof("Event type: %s", eventType)
+func (c *Consumer) process(ctx context.Context, message kafka.Message) error {
+	c.logger.Infof("Received message at topic %s partition %d offset %d", message.Topic, message.Partition, message.Offset)
+	c.logger.Debugf("Message key: %s, value: %s", string(message.Key), string(message.Value))
 
-			if handler, exists := c.handlers[eventType]; exists {
-				if err := handler(ctx, eventType, headers, message.Value); err != nil {
-					c.logger.WithError(err).WithField("event_type", eventType).Error("Failed to handle event")
-				} else {
-					c.logger.WithField("event_type", eventType).Info("Event handled successfully")
-				}
-			} else {
-				c.logger.WithField("event_type", eventType).Warn("No handler registered for event type")
-			}
+	// Extract headers from Kafka message, legacy or CloudEvents
+	headers := types.ParseHeaders(message.Headers)
+	eventType := headers[types.HeaderEventType]
+
+	c.logger.Infof("Event type: %s", eventType)
+	c.sequences.Observe(eventType, headers)
+
+	r, exists := c.handlers[eventType]
+	if !exists {
+		c.logger.WithField("event_type", eventType).Warn("No handler registered for event type")
+		return nil
+	}
+
+	if err := c.handle(ctx, r, message, eventType, headers); err != nil {
+		c.logger.WithError(err).WithField("event_type", eventType).Error("Failed to handle event")
+		return err
+	}
+	return nil
+}
+
+func (c *Consumer) flush() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := c.committer.Flush(ctx); err != nil {
+		c.logger.WithError(err).Error("Failed to flush offsets on shutdown")
+	}
+}
+
+// handle runs the handler with retries and dead-letters the message once the
+// retry policy is exhausted. It only returns an error when the message could
+// neither be handled nor dead-lettered.
+func (c *Consumer) handle(ctx context.Context, r registration, message kafka.Message, eventType string, headers map[string]string) error {
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		if err = c.invoke(ctx, r, eventType, headers, message.Value); err == nil {
+			return nil
+		}
+		if isPermanent(err) || attempt >= r.retry.MaxAttempts {
+			break
 		}
+
+		backoff := r.retry.Backoff(attempt)
+		c.logger.WithError(err).WithFields(logrus.Fields{
+			"event_type": eventType,
+			"attempt":    attempt,
+			"backoff":    backoff,
+		}).Warn("Handler failed, retrying")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+
+	if c.dlq == nil {
+		return fmt.Errorf("handler failed after %d attempts and no dead-letter topic is configured: %w", attempt, err)
+	}
+	if dlqErr := c.dlq.Send(ctx, message, err, attempt); dlqErr != nil {
+		return fmt.Errorf("handler failed after %d attempts (%v): %w", attempt, err, dlqErr)
+	}
+	return nil
+}
+
+// invoke runs the handler once, consulting the dedup store when one is configured.
+func (c *Consumer) invoke(ctx context.Context, r registration, eventType string, headers map[string]string, payload []byte) error {
+	eventID := headers[types.HeaderEventID]
+	if c.dedup == nil || eventID == "" {
+		if err := r.handler(ctx, eventType, headers, payload); err != nil {
+			return err
+		}
+		c.logger.WithField("event_type", eventType).Info("Event handled successfully")
+		return nil
+	}
+
+	processed, err := c.dedup.Process(ctx, eventID, func(ctx context.Context) error {
+		return r.handler(ctx, eventType, headers, payload)
+	})
+	if err != nil {
+		return err
 	}
+
+	entry := c.logger.WithFields(logrus.Fields{
+		"event_id":   eventID,
+		"event_type": eventType,
+	})
+	if processed {
+		entry.Info("Event handled successfully")
+	} else {
+		entry.Info("Skipping already processed event")
+	}
+	return nil
 }
 
 func (c *Consumer) Close() error {
 	c.logger.Info("Closing Kafka reader")
+	if c.dlq != nil {
+		if err := c.dlq.Close(); err != nil {
+			c.logger.WithError(err).Error("Failed to close dead-letter writer")
+		}
+	}
 	return c.reader.Close()
 }
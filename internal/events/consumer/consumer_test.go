@@ -0,0 +1,111 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/kitamersion/go-goservice/internal/events/dedup"
+	"github.com/kitamersion/go-goservice/internal/events/types"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+const testEventType = "test.Event"
+
+// newTestConsumer returns a consumer without a reader or dead-letter topic,
+// deduplicating through store and retrying each handler once.
+func newTestConsumer(store dedup.Store, handler EventHandler) *Consumer {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	c := &Consumer{
+		dedup:     store,
+		logger:    logger,
+		retry:     RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+		sequences: newSequenceTracker(logger),
+		handlers:  make(map[string]registration),
+	}
+	c.RegisterHandler(testEventType, handler)
+	return c
+}
+
+func testMessage(eventID string) kafka.Message {
+	return kafka.Message{
+		Headers: []kafka.Header{
+			{Key: types.HeaderEventID, Value: []byte(eventID)},
+			{Key: types.HeaderEventType, Value: []byte(testEventType)},
+		},
+		Value: []byte(`{}`),
+	}
+}
+
+func TestConsumerDeduplicates(t *testing.T) {
+	errHandler := errors.New("handler failed")
+
+	tests := []struct {
+		name      string
+		failures  []bool // whether each handler call fails, in order; later calls succeed
+		eventIDs  []string
+		wantErrs  []bool // whether each delivery fails
+		wantCalls int
+	}{
+		{
+			name:      "redelivery after success is skipped",
+			eventIDs:  []string{"a", "a"},
+			wantErrs:  []bool{false, false},
+			wantCalls: 1,
+		},
+		{
+			name:      "distinct events both run",
+			eventIDs:  []string{"a", "b"},
+			wantErrs:  []bool{false, false},
+			wantCalls: 2,
+		},
+		{
+			name:      "success on retry is recorded once",
+			failures:  []bool{true},
+			eventIDs:  []string{"a", "a"},
+			wantErrs:  []bool{false, false},
+			wantCalls: 2,
+		},
+		{
+			name:      "failed event is not recorded",
+			failures:  []bool{true, true},
+			eventIDs:  []string{"a", "a", "a"},
+			wantErrs:  []bool{true, false, false},
+			wantCalls: 3,
+		},
+		{
+			name:      "events without an id are not deduplicated",
+			eventIDs:  []string{"", ""},
+			wantErrs:  []bool{false, false},
+			wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			c := newTestConsumer(dedup.NewMemoryStore(0), func(context.Context, string, map[string]string, []byte) error {
+				calls++
+				if calls <= len(tt.failures) && tt.failures[calls-1] {
+					return errHandler
+				}
+				return nil
+			})
+
+			for i, eventID := range tt.eventIDs {
+				err := c.process(context.Background(), testMessage(eventID))
+				if (err != nil) != tt.wantErrs[i] {
+					t.Fatalf("delivery %d: process() = %v, want error %v", i, err, tt.wantErrs[i])
+				}
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handler ran %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
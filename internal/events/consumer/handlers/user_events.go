@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/kitamersion/go-goservice/internal/events/codec"
+	"github.com/kitamersion/go-goservice/internal/events/consumer"
 	"github.com/kitamersion/go-goservice/internal/events/proto/events/userpb"
+	"github.com/kitamersion/go-goservice/internal/events/types"
 	"github.com/sirupsen/logrus"
-	"google.golang.org/protobuf/encoding/protojson"
 )
 
 type UserEventHandlers struct {
@@ -21,17 +23,18 @@ func NewUserEventHandlers(logger *logrus.Logger) *UserEventHandlers {
 
 func (h *UserEventHandlers) HandleUserCreated(ctx context.Context, eventType string, headers map[string]string, payload []byte) error {
 	var event userpb.UserCreated
-	if err := protojson.Unmarshal(payload, &event); err != nil {
+	if err := codec.Unmarshal(headers[types.HeaderContentType], payload, &event); err != nil {
 		h.logger.WithError(err).Error("Failed to unmarshal UserCreated event")
-		return fmt.Errorf("failed to unmarshal UserCreated event: %w", err)
+		return consumer.Permanent(fmt.Errorf("failed to unmarshal UserCreated event: %w", err))
 	}
 
 	h.logger.WithFields(logrus.Fields{
-		"event_id":   headers["event_id"],
-		"event_type": eventType,
-		"user_id":    event.Id,
-		"email":      event.Email,
-		"name":       event.Name,
+		"event_id":          headers[types.HeaderEventID],
+		"event_type":        eventType,
+		"aggregate_version": headers[types.HeaderAggregateVersion],
+		"user_id":           event.Id,
+		"email":             event.Email,
+		"name":              event.Name,
 	}).Info("User created event processed")
 
 	// Add your business logic here
@@ -42,15 +45,16 @@ func (h *UserEventHandlers) HandleUserCreated(ctx context.Context, eventType str
 
 func (h *UserEventHandlers) HandleUserUpdated(ctx context.Context, eventType string, headers map[string]string, payload []byte) error {
 	var event userpb.UserUpdated
-	if err := protojson.Unmarshal(payload, &event); err != nil {
+	if err := codec.Unmarshal(headers[types.HeaderContentType], payload, &event); err != nil {
 		h.logger.WithError(err).Error("Failed to unmarshal UserUpdated event")
-		return fmt.Errorf("failed to unmarshal UserUpdated event: %w", err)
+		return consumer.Permanent(fmt.Errorf("failed to unmarshal UserUpdated event: %w", err))
 	}
 
 	h.logger.WithFields(logrus.Fields{
-		"event_id":   headers["event_id"],
-		"event_type": eventType,
-		"user_id":    event.Id,
+		"event_id":          headers[types.HeaderEventID],
+		"event_type":        eventType,
+		"aggregate_version": headers[types.HeaderAggregateVersion],
+		"user_id":           event.Id,
 	}).Info("User updated event processed")
 
 	// Add your business logic here
@@ -61,15 +65,16 @@ func (h *UserEventHandlers) HandleUserUpdated(ctx context.Context, eventType str
 
 func (h *UserEventHandlers) HandleUserDeleted(ctx context.Context, eventType string, headers map[string]string, payload []byte) error {
 	var event userpb.UserDeleted
-	if err := protojson.Unmarshal(payload, &event); err != nil {
+	if err := codec.Unmarshal(headers[types.HeaderContentType], payload, &event); err != nil {
 		h.logger.WithError(err).Error("Failed to unmarshal UserDeleted event")
-		return fmt.Errorf("failed to unmarshal UserDeleted event: %w", err)
+		return consumer.Permanent(fmt.Errorf("failed to unmarshal UserDeleted event: %w", err))
 	}
 
 	h.logger.WithFields(logrus.Fields{
-		"event_id":   headers["event_id"],
-		"event_type": eventType,
-		"user_id":    event.Id,
+		"event_id":          headers[types.HeaderEventID],
+		"event_type":        eventType,
+		"aggregate_version": headers[types.HeaderAggregateVersion],
+		"user_id":           event.Id,
 	}).Info("User deleted event processed")
 
 	// Add your business logic here
@@ -0,0 +1,76 @@
+package consumer
+
+import (
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// offsetTracker lets messages finish out of order while only ever committing
+// the contiguous prefix of processed offsets on each partition.
+//
+// A rebalance makes the reader fetch a partition again from its committed
+// offset while workers may still be busy with messages fetched before. Each
+// run of a partition is therefore a generation of its own, and a message only
+// counts towards the generation it was fetched in.
+type offsetTracker struct {
+	mu          sync.Mutex
+	partitions  map[int]*partitionOffsets
+	generations uint64 // last generation handed out
+}
+
+type partitionOffsets struct {
+	generation uint64
+	pending    []kafka.Message // fetched but not yet committable, in fetch order
+	done       map[int64]bool
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{
+		partitions: make(map[int]*partitionOffsets),
+	}
+}
+
+// Add registers a fetched message before it is dispatched and returns the
+// generation to pass to Done once it has been processed.
+func (t *offsetTracker) Add(message kafka.Message) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[message.Partition]
+	// After a rebalance the reader restarts from the committed offset, so
+	// anything still tracked for the partition is stale
+	if !ok || (len(p.pending) > 0 && message.Offset <= p.pending[len(p.pending)-1].Offset) {
+		t.generations++
+		p = &partitionOffsets{generation: t.generations, done: make(map[int64]bool)}
+		t.partitions[message.Partition] = p
+	}
+	p.pending = append(p.pending, message)
+	return p.generation
+}
+
+// Done marks a message of the given generation as processed. It returns the
+// highest message below which every offset of the partition has been
+// processed, and false if that watermark did not move. Messages of an older
+// generation are ignored: the same offsets have been fetched again and must be
+// processed again before they can be committed.
+func (t *offsetTracker) Done(message kafka.Message, generation uint64) (kafka.Message, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[message.Partition]
+	if !ok || p.generation != generation {
+		return kafka.Message{}, false
+	}
+	p.done[message.Offset] = true
+
+	var last kafka.Message
+	advanced := false
+	for len(p.pending) > 0 && p.done[p.pending[0].Offset] {
+		last = p.pending[0]
+		delete(p.done, last.Offset)
+		p.pending = p.pending[1:]
+		advanced = true
+	}
+	return last, advanced
+}
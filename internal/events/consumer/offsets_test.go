@@ -0,0 +1,115 @@
+package consumer
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestOffsetTrackerDone(t *testing.T) {
+	type step struct {
+		partition int
+		offset    int64
+		want      int64 // committable offset after the step, -1 if it did not move
+	}
+
+	tests := []struct {
+		name    string
+		fetched map[int][]int64
+		steps   []step
+	}{
+		{
+			name:    "in order",
+			fetched: map[int][]int64{0: {0, 1, 2}},
+			steps:   []step{{0, 0, 0}, {0, 1, 1}, {0, 2, 2}},
+		},
+		{
+			name:    "out of order",
+			fetched: map[int][]int64{0: {0, 1, 2, 3}},
+			steps:   []step{{0, 2, -1}, {0, 1, -1}, {0, 3, -1}, {0, 0, 3}},
+		},
+		{
+			name:    "watermark stops at the first unfinished offset",
+			fetched: map[int][]int64{0: {0, 1, 2, 3}},
+			steps:   []step{{0, 0, 0}, {0, 2, -1}, {0, 3, -1}, {0, 1, 3}},
+		},
+		{
+			name:    "gaps between offsets",
+			fetched: map[int][]int64{0: {10, 12, 15, 20}},
+			steps:   []step{{0, 15, -1}, {0, 10, 10}, {0, 20, -1}, {0, 12, 20}},
+		},
+		{
+			name:    "gaps out of order",
+			fetched: map[int][]int64{0: {5, 9, 30}},
+			steps:   []step{{0, 30, -1}, {0, 9, -1}, {0, 5, 30}},
+		},
+		{
+			name:    "partitions are independent",
+			fetched: map[int][]int64{0: {0, 1}, 1: {7, 8}},
+			steps:   []step{{1, 8, -1}, {0, 1, -1}, {1, 7, 8}, {0, 0, 1}},
+		},
+		{
+			name:    "unknown partition",
+			fetched: map[int][]int64{0: {0}},
+			steps:   []step{{2, 0, -1}, {0, 0, 0}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newOffsetTracker()
+			generations := make(map[int]uint64)
+			for partition, offsets := range tt.fetched {
+				for _, offset := range offsets {
+					generations[partition] = tracker.Add(kafka.Message{Partition: partition, Offset: offset})
+				}
+			}
+
+			for i, s := range tt.steps {
+				got, advanced := tracker.Done(kafka.Message{Partition: s.partition, Offset: s.offset}, generations[s.partition])
+				switch {
+				case s.want < 0 && advanced:
+					t.Fatalf("step %d: done %d/%d moved the watermark to %d, want no change", i, s.partition, s.offset, got.Offset)
+				case s.want >= 0 && !advanced:
+					t.Fatalf("step %d: done %d/%d did not move the watermark, want %d", i, s.partition, s.offset, s.want)
+				case s.want >= 0 && (got.Offset != s.want || got.Partition != s.partition):
+					t.Fatalf("step %d: done %d/%d moved the watermark to %d/%d, want %d/%d", i, s.partition, s.offset, got.Partition, got.Offset, s.partition, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestOffsetTrackerRebalance(t *testing.T) {
+	tracker := newOffsetTracker()
+	before := tracker.Add(kafka.Message{Partition: 0, Offset: 5})
+	tracker.Add(kafka.Message{Partition: 0, Offset: 6})
+
+	// The reader starts over from the committed offset after a rebalance;
+	// offsets fetched before it must not hold the watermark back
+	after := tracker.Add(kafka.Message{Partition: 0, Offset: 5})
+	tracker.Add(kafka.Message{Partition: 0, Offset: 6})
+	if after == before {
+		t.Fatal("refetching the partition did not start a new generation")
+	}
+
+	// A worker still busy with offset 6 from before finishes first; it must
+	// not count for the refetched offset 6, which has not been processed yet
+	if _, advanced := tracker.Done(kafka.Message{Partition: 0, Offset: 6}, before); advanced {
+		t.Fatal("offset 6 from before the rebalance moved the watermark")
+	}
+	got, advanced := tracker.Done(kafka.Message{Partition: 0, Offset: 5}, after)
+	if !advanced || got.Offset != 5 {
+		t.Fatalf("watermark = %d (advanced %v), want 5", got.Offset, advanced)
+	}
+	got, advanced = tracker.Done(kafka.Message{Partition: 0, Offset: 6}, after)
+	if !advanced || got.Offset != 6 {
+		t.Fatalf("watermark = %d (advanced %v), want 6", got.Offset, advanced)
+	}
+
+	// A stale completion of an offset the new generation already committed
+	// changes nothing either
+	if _, advanced := tracker.Done(kafka.Message{Partition: 0, Offset: 5}, before); advanced {
+		t.Fatal("offset 5 from before the rebalance moved the watermark")
+	}
+}
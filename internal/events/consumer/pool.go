@@ -0,0 +1,119 @@
+package consumer
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const workerQueueSize = 64
+
+// trackedMessage is a message along with the offset tracker generation it was
+// fetched in.
+type trackedMessage struct {
+	kafka.Message
+	generation uint64
+}
+
+// consumeConcurrently fans messages out to a fixed pool of workers. Messages
+// with the same ordering key (Kafka key or partition) always land on the same
+// worker, so they are processed in order while unrelated keys run in parallel.
+func (c *Consumer) consumeConcurrently(ctx context.Context) error {
+	c.logger.Infof("Processing messages with %d workers, ordered by %s", c.concurrency, c.orderingKey())
+
+	workCtx, stopWork := context.WithCancel(ctx)
+	defer stopWork()
+
+	tracker := newOffsetTracker()
+	queues := make([]chan trackedMessage, c.concurrency)
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i := range queues {
+		queues[i] = make(chan trackedMessage, workerQueueSize)
+		wg.Add(1)
+		go func(queue <-chan trackedMessage) {
+			defer wg.Done()
+			for message := range queue {
+				if workCtx.Err() != nil {
+					// Shutting down: leave the rest uncommitted for redelivery
+					continue
+				}
+				if err := c.process(workCtx, message.Message); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						stopWork()
+					})
+					continue
+				}
+				if committable, ok := tracker.Done(message.Message, message.generation); ok {
+					if err := c.committer.MarkDone(workCtx, committable); err != nil {
+						c.logger.WithError(err).Error("Failed to commit offsets")
+					}
+				}
+			}
+		}(queues[i])
+	}
+
+	c.dispatch(workCtx, tracker, queues)
+
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	c.logger.Info("Context cancelled, shutting down consumer")
+	return ctx.Err()
+}
+
+func (c *Consumer) dispatch(ctx context.Context, tracker *offsetTracker, queues []chan trackedMessage) {
+	for {
+		c.logger.Debug("Waiting to fetch message...")
+		message, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			c.logger.WithError(err).Error("Failed to fetch message")
+			time.Sleep(time.Second) // backoff on error
+			continue
+		}
+
+		generation := tracker.Add(message)
+		select {
+		case queues[c.workerFor(message, len(queues))] <- trackedMessage{Message: message, generation: generation}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Consumer) orderingKey() string {
+	if c.orderBy == "partition" {
+		return "partition"
+	}
+	return "key"
+}
+
+// workerFor picks the worker for a message. Messages without a key fall back
+// to their partition, which Kafka already orders.
+func (c *Consumer) workerFor(message kafka.Message, workers int) int {
+	key := message.Key
+	if c.orderingKey() == "partition" || len(key) == 0 {
+		key = []byte(strconv.Itoa(message.Partition))
+	}
+
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % uint32(workers))
+}
@@ -0,0 +1,74 @@
+package consumer
+
+import (
+	"errors"
+	"time"
+
+	"github.com/kitamersion/go-goservice/internal/config"
+)
+
+const (
+	defaultMaxAttempts    = 3
+	defaultInitialBackoff = 200 * time.Millisecond
+	defaultMaxBackoff     = 10 * time.Second
+)
+
+// RetryPolicy describes how often a failing handler is retried before the
+// message is dead-lettered.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+func NewRetryPolicy(cfg *config.RetryConfig) RetryPolicy {
+	policy := RetryPolicy{
+		MaxAttempts:    cfg.MaxAttempts,
+		InitialBackoff: cfg.InitialBackoff,
+		MaxBackoff:     cfg.MaxBackoff,
+	}
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = defaultMaxAttempts
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = defaultInitialBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = defaultMaxBackoff
+	}
+	return policy
+}
+
+// Backoff returns the delay before the given retry (1 = first retry), doubling
+// each time up to MaxBackoff.
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < retry; i++ {
+		backoff *= 2
+		if backoff >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return backoff
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a handler error as not worth retrying, e.g. a payload that
+// cannot be decoded. The message goes straight to the dead-letter topic.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
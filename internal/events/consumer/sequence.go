@@ -0,0 +1,58 @@
+package consumer
+
+import (
+	"strconv"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/kitamersion/go-goservice/internal/events/types"
+	"github.com/sirupsen/logrus"
+)
+
+const sequenceCacheSize = 10000
+
+// sequenceTracker remembers the last version seen per aggregate and warns
+// about gaps (missed events) and stale events (older than one already seen).
+// Only recently active aggregates are tracked.
+type sequenceTracker struct {
+	versions *lru.Cache[string, int64]
+	logger   *logrus.Logger
+}
+
+func newSequenceTracker(logger *logrus.Logger) *sequenceTracker {
+	versions, _ := lru.New[string, int64](sequenceCacheSize)
+	return &sequenceTracker{
+		versions: versions,
+		logger:   logger,
+	}
+}
+
+// Observe checks the aggregate headers of a message. Messages without them are
+// ignored.
+func (t *sequenceTracker) Observe(eventType string, headers map[string]string) {
+	aggregateID := headers[types.HeaderAggregateID]
+	version, err := strconv.ParseInt(headers[types.HeaderAggregateVersion], 10, 64)
+	if aggregateID == "" || err != nil {
+		return
+	}
+
+	last, seen := t.versions.Get(aggregateID)
+	if !seen {
+		t.versions.Add(aggregateID, version)
+		return
+	}
+
+	entry := t.logger.WithFields(logrus.Fields{
+		"event_type":        eventType,
+		"aggregate_id":      aggregateID,
+		"aggregate_version": version,
+		"last_version":      last,
+	})
+	switch {
+	case version <= last:
+		entry.Warn("Received stale event for aggregate")
+		return
+	case version > last+1:
+		entry.Warn("Detected gap in aggregate event sequence")
+	}
+	t.versions.Add(aggregateID, version)
+}
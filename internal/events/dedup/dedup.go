@@ -0,0 +1,14 @@
+package dedup
+
+import (
+	"context"
+)
+
+// Store makes event handling idempotent by remembering which event IDs a
+// consumer group has already processed.
+type Store interface {
+	// Process runs fn unless eventID was processed before and records it once
+	// fn succeeded. It reports false when the event was a duplicate and fn was
+	// skipped.
+	Process(ctx context.Context, eventID string, fn func(ctx context.Context) error) (bool, error)
+}
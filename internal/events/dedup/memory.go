@@ -0,0 +1,54 @@
+package dedup
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process Store intended for tests and local runs.
+// Entries expire after the TTL; a zero TTL keeps them forever.
+type MemoryStore struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	processed map[string]time.Time
+}
+
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		ttl:       ttl,
+		processed: make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryStore) Process(ctx context.Context, eventID string, fn func(ctx context.Context) error) (bool, error) {
+	if s.Seen(eventID) {
+		return false, nil
+	}
+
+	if err := fn(ctx); err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	s.processed[eventID] = time.Now()
+	s.mu.Unlock()
+	return true, nil
+}
+
+// Seen reports whether eventID was processed and has not expired yet.
+func (s *MemoryStore) Seen(eventID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	processedAt, ok := s.processed[eventID]
+	if !ok {
+		return false
+	}
+	if s.ttl > 0 && time.Since(processedAt) > s.ttl {
+		delete(s.processed, eventID)
+		return false
+	}
+	return true
+}
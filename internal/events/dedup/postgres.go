@@ -0,0 +1,90 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kitamersion/go-goservice/internal/database"
+	"github.com/kitamersion/go-goservice/internal/domain/repositories"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTTL             = 7 * 24 * time.Hour
+	defaultCleanupInterval = time.Hour
+)
+
+var errDuplicate = errors.New("event already processed")
+
+// PostgresStore records processed events in the same transaction as the
+// handler. The transaction is attached to the handler's context, so handler
+// writes made through database.TxFromContext (or a Transactor) commit or roll
+// back together with the dedup record.
+type PostgresStore struct {
+	db            *gorm.DB
+	repo          repositories.ProcessedEventRepository
+	consumerGroup string
+	ttl           time.Duration
+	logger        *logrus.Logger
+}
+
+func NewPostgresStore(db *gorm.DB, repo repositories.ProcessedEventRepository, consumerGroup string, ttl time.Duration, logger *logrus.Logger) *PostgresStore {
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	return &PostgresStore{
+		db:            db,
+		repo:          repo,
+		consumerGroup: consumerGroup,
+		ttl:           ttl,
+		logger:        logger,
+	}
+}
+
+func (s *PostgresStore) Process(ctx context.Context, eventID string, fn func(ctx context.Context) error) (bool, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Inserting first locks the key, so a concurrent redelivery waits for
+		// this transaction and then sees the conflict
+		recorded, err := s.repo.WithTx(tx).Record(eventID, s.consumerGroup, time.Now())
+		if err != nil {
+			return err
+		}
+		if !recorded {
+			return errDuplicate
+		}
+		return fn(database.ContextWithTx(ctx, tx))
+	})
+	if errors.Is(err, errDuplicate) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// StartCleanup deletes records older than the TTL on every interval until ctx
+// is cancelled.
+func (s *PostgresStore) StartCleanup(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultCleanupInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := s.repo.DeleteOlderThan(time.Now().Add(-s.ttl))
+			if err != nil {
+				s.logger.WithError(err).Error("Failed to clean up processed events")
+				continue
+			}
+			s.logger.Debugf("Deleted %d expired processed events", deleted)
+		}
+	}
+}
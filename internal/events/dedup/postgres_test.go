@@ -0,0 +1,93 @@
+//go:build integration
+
+package dedup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"github.com/kitamersion/go-goservice/internal/domain/repositories"
+	"github.com/sirupsen/logrus"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Run with a disposable database, e.g.
+//
+//	TEST_DATABASE_DSN="host=localhost user=postgres password=postgres dbname=test sslmode=disable" \
+//		go test -tags integration ./internal/events/dedup/
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	if err := db.AutoMigrate(&entities.UserEntity{}, &entities.Event{}, &entities.ProcessedEvent{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return db
+}
+
+func TestPostgresStoreCommitsWithTheHandler(t *testing.T) {
+	db := openTestDB(t)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	users := repositories.NewUserRepository(db)
+	transactor := repositories.NewTransactor(db, users, repositories.NewEventRepository(db))
+	store := NewPostgresStore(db, repositories.NewProcessedEventRepository(db), "test-"+uuid.NewString(), time.Hour, logger)
+
+	eventID := uuid.NewString()
+	user := &entities.UserEntity{ID: uuid.New(), Name: "Jane", Email: uuid.NewString() + "@example.com", Version: 1}
+	errHandler := errors.New("handler failed")
+
+	// The handler writes through the transaction on its context and then
+	// fails: neither its write nor the dedup record may survive
+	handle := func(fail bool) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			err := transactor.WithinTransaction(ctx, func(repos repositories.Repositories) error {
+				return repos.Users.Create(user)
+			})
+			if err != nil {
+				return err
+			}
+			if fail {
+				return errHandler
+			}
+			return nil
+		}
+	}
+
+	if processed, err := store.Process(context.Background(), eventID, handle(true)); !errors.Is(err, errHandler) || processed {
+		t.Fatalf("Process() of a failing handler = %v, %v; want false, %v", processed, err, errHandler)
+	}
+	if _, err := users.GetByID(user.ID); err == nil {
+		t.Fatal("write of the failed handler was committed")
+	}
+
+	if processed, err := store.Process(context.Background(), eventID, handle(false)); err != nil || !processed {
+		t.Fatalf("Process() of the redelivery = %v, %v; want true, nil", processed, err)
+	}
+	if _, err := users.GetByID(user.ID); err != nil {
+		t.Fatalf("write of the handler was not committed: %v", err)
+	}
+
+	calls := 0
+	processed, err := store.Process(context.Background(), eventID, func(context.Context) error {
+		calls++
+		return nil
+	})
+	if err != nil || processed || calls != 0 {
+		t.Fatalf("Process() of a duplicate = %v, %v after %d calls; want false, nil without calling the handler", processed, err, calls)
+	}
+}
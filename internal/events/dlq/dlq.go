@@ -0,0 +1,107 @@
+package dlq
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// Headers added to a message when it is dead-lettered. The original headers
+// are kept as they are.
+const (
+	HeaderError             = "dlq_error"
+	HeaderAttempts          = "dlq_attempts"
+	HeaderOriginalTopic     = "dlq_original_topic"
+	HeaderOriginalPartition = "dlq_original_partition"
+	HeaderOriginalOffset    = "dlq_original_offset"
+	HeaderFailedAt          = "dlq_failed_at"
+
+	headerPrefix = "dlq_"
+)
+
+// Writer sends messages that could not be handled to the dead-letter topic.
+type Writer struct {
+	writer *kafka.Writer
+	logger *logrus.Logger
+}
+
+func NewWriter(brokers []string, topic string, logger *logrus.Logger) *Writer {
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{}, // dead letters of one aggregate stay on one partition, in order
+		RequiredAcks: kafka.RequireAll,
+		BatchSize:    1,
+		BatchTimeout: 10 * time.Millisecond,
+	}
+
+	return &Writer{
+		writer: writer,
+		logger: logger,
+	}
+}
+
+// Send dead-letters the original message together with the failure reason and
+// where it came from, so it can be inspected and re-driven later.
+func (w *Writer) Send(ctx context.Context, original kafka.Message, reason error, attempts int) error {
+	headers := make([]kafka.Header, 0, len(original.Headers)+6)
+	headers = append(headers, original.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: HeaderError, Value: []byte(reason.Error())},
+		kafka.Header{Key: HeaderAttempts, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: HeaderOriginalTopic, Value: []byte(original.Topic)},
+		kafka.Header{Key: HeaderOriginalPartition, Value: []byte(strconv.Itoa(original.Partition))},
+		kafka.Header{Key: HeaderOriginalOffset, Value: []byte(strconv.FormatInt(original.Offset, 10))},
+		kafka.Header{Key: HeaderFailedAt, Value: []byte(fmt.Sprint(time.Now().Unix()))},
+	)
+
+	message := kafka.Message{
+		Key:     original.Key,
+		Value:   original.Value,
+		Headers: headers,
+	}
+
+	if err := w.writer.WriteMessages(ctx, message); err != nil {
+		return fmt.Errorf("failed to write message to dead-letter topic: %w", err)
+	}
+
+	w.logger.WithFields(logrus.Fields{
+		"topic":     original.Topic,
+		"partition": original.Partition,
+		"offset":    original.Offset,
+		"attempts":  attempts,
+	}).Warn("Message sent to dead-letter topic")
+
+	return nil
+}
+
+func (w *Writer) Close() error {
+	return w.writer.Close()
+}
+
+// OriginalTopic returns the topic a dead-lettered message was consumed from.
+func OriginalTopic(message kafka.Message) string {
+	for _, header := range message.Headers {
+		if header.Key == HeaderOriginalTopic {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// StripHeaders removes the dead-letter bookkeeping headers, leaving the
+// headers the message was originally published with.
+func StripHeaders(headers []kafka.Header) []kafka.Header {
+	stripped := make([]kafka.Header, 0, len(headers))
+	for _, header := range headers {
+		if !strings.HasPrefix(header.Key, headerPrefix) {
+			stripped = append(stripped, header)
+		}
+	}
+	return stripped
+}
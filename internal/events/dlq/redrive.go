@@ -0,0 +1,76 @@
+package dlq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// RedriveOptions controls a re-drive run.
+type RedriveOptions struct {
+	// DefaultTopic is used when a message carries no original topic header.
+	DefaultTopic string
+	// Max stops after this many messages; 0 means no limit.
+	Max int
+	// IdleTimeout stops the run once no message arrived for this long.
+	IdleTimeout time.Duration
+	// DryRun logs what would be re-driven without writing or committing.
+	DryRun bool
+}
+
+// Redrive moves dead-lettered messages back onto their source topic. Offsets
+// are committed only after the message was written, so an interrupted run
+// never drops messages.
+func Redrive(ctx context.Context, reader *kafka.Reader, writer *kafka.Writer, opts RedriveOptions, logger *logrus.Logger) (int, error) {
+	redriven := 0
+	for opts.Max == 0 || redriven < opts.Max {
+		fetchCtx, cancel := context.WithTimeout(ctx, opts.IdleTimeout)
+		message, err := reader.FetchMessage(fetchCtx)
+		cancel()
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				logger.Info("Dead-letter topic drained")
+				return redriven, nil
+			}
+			return redriven, fmt.Errorf("failed to fetch dead-lettered message: %w", err)
+		}
+
+		topic := OriginalTopic(message)
+		if topic == "" {
+			topic = opts.DefaultTopic
+		}
+
+		entry := logger.WithFields(logrus.Fields{
+			"topic":  topic,
+			"offset": message.Offset,
+		})
+		if opts.DryRun {
+			entry.Info("Would re-drive message")
+			redriven++
+			continue
+		}
+
+		err = writer.WriteMessages(ctx, kafka.Message{
+			Topic:   topic,
+			Key:     message.Key, // the aggregate ID, so the hash balancer picks its partition
+			Value:   message.Value,
+			Headers: StripHeaders(message.Headers),
+		})
+		if err != nil {
+			return redriven, fmt.Errorf("failed to re-drive message: %w", err)
+		}
+
+		if err := reader.CommitMessages(ctx, message); err != nil {
+			return redriven, fmt.Errorf("failed to commit dead-letter offset: %w", err)
+		}
+
+		entry.Info("Message re-driven")
+		redriven++
+	}
+
+	return redriven, nil
+}
@@ -0,0 +1,319 @@
+// Package hub fans user events out from Kafka to in-process subscribers, such
+// as GraphQL subscriptions.
+package hub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kitamersion/go-goservice/internal/config"
+	"github.com/kitamersion/go-goservice/internal/events/codec"
+	"github.com/kitamersion/go-goservice/internal/events/proto/events/userpb"
+	"github.com/kitamersion/go-goservice/internal/events/types"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+)
+
+const defaultBufferSize = 64
+
+// Event types the hub delivers, the full names of the userpb messages.
+var (
+	TypeUserCreated = eventType(&userpb.UserCreated{})
+	TypeUserUpdated = eventType(&userpb.UserUpdated{})
+	TypeUserDeleted = eventType(&userpb.UserDeleted{})
+)
+
+// ErrSlowSubscriber is reported for a subscription that was closed because
+// its buffer filled up.
+var ErrSlowSubscriber = errors.New("subscriber did not keep up with events")
+
+// Event is a decoded user event. Name and Email are only set for UserCreated.
+type Event struct {
+	ID         string
+	Type       string
+	UserID     string
+	Version    int64 // aggregate version, 0 when unknown
+	OccurredAt time.Time
+	Name       string
+	Email      string
+}
+
+// Hub reads every partition of the user events topic, starting at the newest
+// offset, and passes each event to the subscriptions interested in its type.
+// It reads without a consumer group, so every instance sees every event and
+// nothing is left behind on the broker when it exits; it only delivers events
+// that arrive while it runs and never commits offsets. Partitions added to the
+// topic later are picked up on the next start.
+type Hub struct {
+	brokers    []string
+	topic      string
+	bufferSize int
+	logger     *logrus.Logger
+
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+func NewHub(kafkaCfg *config.KafkaConfig, cfg *config.SubscriptionConfig, logger *logrus.Logger) *Hub {
+	bufferSize := cfg.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+
+	return &Hub{
+		brokers:    kafkaCfg.Brokers,
+		topic:      kafkaCfg.Topics.UserEvents,
+		bufferSize: bufferSize,
+		logger:     logger,
+		subs:       make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe registers a subscription for the given event types, or for all
+// of them when types is empty. The caller must Close it when done.
+func (h *Hub) Subscribe(eventTypes []string) *Subscription {
+	sub := &Subscription{
+		hub:    h,
+		events: make(chan Event, h.bufferSize),
+	}
+	if len(eventTypes) > 0 {
+		sub.types = make(map[string]bool, len(eventTypes))
+		for _, t := range eventTypes {
+			sub.types[t] = true
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		sub.closeLocked(nil)
+		return sub
+	}
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+// Start reads events until ctx is cancelled, then closes every subscription.
+func (h *Hub) Start(ctx context.Context) error {
+	h.logger.Info("Starting live event hub")
+	defer h.shutdown()
+
+	partitions, err := h.lookupPartitions(ctx)
+	if err != nil {
+		return err
+	}
+	h.logger.WithField("partitions", len(partitions)).Info("Reading live events")
+
+	readers := make([]*kafka.Reader, 0, len(partitions))
+	for _, partition := range partitions {
+		reader := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:   h.brokers,
+			Topic:     h.topic,
+			Partition: partition.ID,
+			MinBytes:  1,
+			MaxBytes:  10e6,
+		})
+		readers = append(readers, reader)
+		// Subscribers only want events from now on
+		if err := reader.SetOffset(kafka.LastOffset); err != nil {
+			for _, reader := range readers {
+				_ = reader.Close()
+			}
+			return fmt.Errorf("failed to position live event reader: %w", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, reader := range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.read(ctx, reader)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// lookupPartitions asks the brokers for the partitions of the topic, retrying
+// until one answers or ctx is cancelled.
+func (h *Hub) lookupPartitions(ctx context.Context) ([]kafka.Partition, error) {
+	for {
+		var err error
+		for _, broker := range h.brokers {
+			var partitions []kafka.Partition
+			partitions, err = kafka.DefaultDialer.LookupPartitions(ctx, "tcp", broker, h.topic)
+			if err == nil {
+				return partitions, nil
+			}
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		h.logger.WithError(err).Error("Failed to look up live event partitions")
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// read delivers the events of one partition until ctx is cancelled.
+func (h *Hub) read(ctx context.Context, reader *kafka.Reader) {
+	defer func() {
+		if err := reader.Close(); err != nil {
+			h.logger.WithError(err).Error("Failed to close live event reader")
+		}
+	}()
+
+	for {
+		message, err := reader.ReadMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			h.logger.WithError(err).Error("Failed to fetch live event")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		event, ok, err := decode(message)
+		if err != nil {
+			h.logger.WithError(err).WithFields(logrus.Fields{
+				"partition": message.Partition,
+				"offset":    message.Offset,
+			}).Warn("Skipping undecodable live event")
+			continue
+		}
+		if ok {
+			h.publish(event)
+		}
+	}
+}
+
+func (h *Hub) publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs {
+		if sub.types != nil && !sub.types[event.Type] {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			// Dropping events silently would leave the client with a wrong
+			// picture; ending the subscription lets it resubscribe and resync
+			delete(h.subs, sub)
+			sub.closeLocked(ErrSlowSubscriber)
+			h.logger.Warn("Closed live event subscription that fell behind")
+		}
+	}
+}
+
+func (h *Hub) shutdown() {
+	h.mu.Lock()
+	h.closed = true
+	for sub := range h.subs {
+		delete(h.subs, sub)
+		sub.closeLocked(nil)
+	}
+	h.mu.Unlock()
+}
+
+// Subscription receives events on Events until it is closed, by its owner,
+// by the hub when it shuts down, or for falling behind.
+type Subscription struct {
+	hub    *Hub
+	types  map[string]bool
+	events chan Event
+	done   bool
+	err    error
+}
+
+// Events is closed when the subscription ends.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Err reports why the hub ended the subscription, once Events is closed.
+func (s *Subscription) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.err
+}
+
+// Close unsubscribes; it is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	delete(s.hub.subs, s)
+	s.closeLocked(nil)
+}
+
+func (s *Subscription) closeLocked(err error) {
+	if s.done {
+		return
+	}
+	s.done = true
+	s.err = err
+	close(s.events)
+}
+
+// decode turns a Kafka message into an Event; ok is false for event types
+// the hub does not deliver.
+func decode(message kafka.Message) (event Event, ok bool, err error) {
+	headers := types.ParseHeaders(message.Headers)
+	event = Event{
+		ID:   headers[types.HeaderEventID],
+		Type: headers[types.HeaderEventType],
+	}
+	if version, err := strconv.ParseInt(headers[types.HeaderAggregateVersion], 10, 64); err == nil {
+		event.Version = version
+	}
+
+	contentType := headers[types.HeaderContentType]
+	switch event.Type {
+	case TypeUserCreated:
+		var payload userpb.UserCreated
+		if err := codec.Unmarshal(contentType, message.Value, &payload); err != nil {
+			return Event{}, false, err
+		}
+		event.UserID = payload.Id
+		event.Name = payload.Name
+		event.Email = payload.Email
+		event.OccurredAt = time.Unix(payload.CreatedAt, 0).UTC()
+	case TypeUserUpdated:
+		var payload userpb.UserUpdated
+		if err := codec.Unmarshal(contentType, message.Value, &payload); err != nil {
+			return Event{}, false, err
+		}
+		event.UserID = payload.Id
+		event.OccurredAt = time.Unix(payload.UpdatedAt, 0).UTC()
+	case TypeUserDeleted:
+		var payload userpb.UserDeleted
+		if err := codec.Unmarshal(contentType, message.Value, &payload); err != nil {
+			return Event{}, false, err
+		}
+		event.UserID = payload.Id
+		event.OccurredAt = time.Unix(payload.DeletedAt, 0).UTC()
+	default:
+		return Event{}, false, nil
+	}
+	return event, true, nil
+}
+
+func eventType(message proto.Message) string {
+	return string(message.ProtoReflect().Descriptor().FullName())
+}
@@ -39,6 +39,11 @@ func InitKafkaTopics(cfg *config.KafkaConfig, logger *logrus.Logger) error {
 			NumPartitions:     3,
 			ReplicationFactor: 1,
 		},
+		{
+			Topic:             cfg.Topics.UserEventsDLQ,
+			NumPartitions:     1,
+			ReplicationFactor: 1,
+		},
 		// Add more topics here
 		// {Topic: cfg.Topics.SomeOtherTopic, NumPartitions: 3, ReplicationFactor: 1},
 	}
@@ -0,0 +1,47 @@
+package outbox
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics exposes the health of the outbox relay.
+type Metrics struct {
+	backlog        prometheus.Gauge
+	oldestUnsent   prometheus.Gauge
+	published      prometheus.Counter
+	publishFailure prometheus.Counter
+	leader         prometheus.Gauge
+	parked         prometheus.Gauge
+}
+
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		backlog: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "outbox_backlog_events",
+			Help: "Number of outbox events waiting to be published.",
+		}),
+		oldestUnsent: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "outbox_oldest_unsent_age_seconds",
+			Help: "Age of the oldest unpublished outbox event.",
+		}),
+		published: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "outbox_published_events_total",
+			Help: "Number of outbox events published to Kafka.",
+		}),
+		publishFailure: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "outbox_publish_failures_total",
+			Help: "Number of failed attempts to publish an outbox event.",
+		}),
+		leader: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "outbox_relay_leader",
+			Help: "1 if this relay held the outbox lock during its last poll.",
+		}),
+		parked: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "outbox_parked_events",
+			Help: "Number of outbox events the relay gave up on after outbox.max_attempts.",
+		}),
+	}
+
+	reg.MustRegister(m.backlog, m.oldestUnsent, m.published, m.publishFailure, m.leader, m.parked)
+	return m
+}
@@ -0,0 +1,47 @@
+package outbox
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// NewEvent builds an outbox row for a proto event of the given aggregate. The
+// row is stored as protojson so it stays readable in the jsonb column; the
+// aggregate version is assigned when the row is written.
+func NewEvent(aggregateID string, protoEvent proto.Message) (*entities.Event, error) {
+	payload, err := protojson.Marshal(protoEvent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize proto event: %w", err)
+	}
+
+	return &entities.Event{
+		ID:          uuid.New(),
+		Type:        string(protoEvent.ProtoReflect().Descriptor().FullName()),
+		AggregateID: aggregateID,
+		Payload:     string(payload),
+		CreatedAt:   time.Now(),
+	}, nil
+}
+
+// Decode turns an outbox row back into its proto message using the global
+// proto registry, so any compiled-in event type can be relayed.
+func Decode(event *entities.Event) (proto.Message, error) {
+	messageType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(event.Type))
+	if err != nil {
+		return nil, fmt.Errorf("unknown event type %q: %w", event.Type, err)
+	}
+
+	message := messageType.New().Interface()
+	if err := protojson.Unmarshal([]byte(event.Payload), message); err != nil {
+		return nil, fmt.Errorf("failed to deserialize %s payload: %w", event.Type, err)
+	}
+
+	return message, nil
+}
@@ -0,0 +1,319 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kitamersion/go-goservice/internal/config"
+	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"github.com/kitamersion/go-goservice/internal/domain/repositories"
+	"github.com/kitamersion/go-goservice/internal/events/producer"
+	"github.com/kitamersion/go-goservice/internal/events/types"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPollInterval   = time.Second
+	defaultBatchSize      = 100
+	defaultMaxAttempts    = 10
+	defaultPublishTimeout = 10 * time.Second
+
+	// LockKey identifies the Postgres advisory lock that elects the publishing
+	// relay.
+	LockKey int64 = 0x6f7574626f78 // "outbox"
+)
+
+// Lock elects the relay that publishes; *database.AdvisoryLock implements it.
+type Lock interface {
+	TryAcquire(ctx context.Context) (bool, error)
+	Release() error
+}
+
+// Publisher sends events to Kafka; *producer.Producer implements it.
+type Publisher interface {
+	PublishBatch(ctx context.Context, envelopes []producer.Envelope) error
+}
+
+type RelayOption func(*Relay)
+
+// WithMetrics reports backlog and delivery metrics on every poll.
+func WithMetrics(metrics *Metrics) RelayOption {
+	return func(r *Relay) {
+		r.metrics = metrics
+	}
+}
+
+// errUndecodable marks outbox rows that cannot be turned back into a proto
+// message, such as an event type this binary was not built with.
+var errUndecodable = errors.New("undecodable outbox event")
+
+// Relay polls the outbox for unpublished events and sends them to Kafka.
+// Rows are only marked as published after the broker acknowledged them, so
+// delivery is at-least-once. Only the relay holding the lock publishes, so any
+// number of relays can run side by side while ordering is preserved.
+//
+// The lock is a session-level advisory lock rather than one scoped to a
+// transaction, so no transaction is open while waiting on Kafka: a batch is
+// read, published with a timeout, then marked in a short transaction. If the
+// leader's lock connection drops mid-batch another relay may publish the same
+// events again, which at-least-once delivery allows.
+//
+// An event that fails for reasons of its own (it cannot be decoded, or the
+// broker refuses the message) is parked after maxAttempts, so it cannot block
+// the events behind it. Later events of the same aggregate are then published
+// with a gap in their versions, which consumers report. Broker outages never
+// park events.
+type Relay struct {
+	eventRepo      repositories.EventRepository
+	transactor     repositories.Transactor
+	producer       Publisher
+	lock           Lock
+	logger         *logrus.Logger
+	metrics        *Metrics
+	pollInterval   time.Duration
+	batchSize      int
+	maxAttempts    int
+	publishTimeout time.Duration
+}
+
+func NewRelay(eventRepo repositories.EventRepository, transactor repositories.Transactor, producer Publisher, lock Lock, cfg *config.OutboxConfig, logger *logrus.Logger, opts ...RelayOption) *Relay {
+	pollInterval := cfg.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	publishTimeout := cfg.PublishTimeout
+	if publishTimeout <= 0 {
+		publishTimeout = defaultPublishTimeout
+	}
+
+	r := &Relay{
+		eventRepo:      eventRepo,
+		transactor:     transactor,
+		producer:       producer,
+		lock:           lock,
+		logger:         logger,
+		pollInterval:   pollInterval,
+		batchSize:      batchSize,
+		maxAttempts:    maxAttempts,
+		publishTimeout: publishTimeout,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *Relay) Start(ctx context.Context) error {
+	r.logger.Info("Starting outbox relay")
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+	defer r.releaseLock()
+
+	for {
+		// Keep draining while full batches come back, then wait for the next tick
+		for ctx.Err() == nil {
+			published, err := r.relayBatch(ctx)
+			if err != nil {
+				r.logger.WithError(err).Error("Failed to relay outbox events")
+				break
+			}
+			if published < r.batchSize {
+				break
+			}
+		}
+		r.reportBacklog()
+
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Context cancelled, shutting down outbox relay")
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// relayBatch publishes one batch with a single producer call and marks the
+// events published up to the first failure, so later events never overtake an
+// earlier one. Events after a failure that did reach Kafka are sent again with
+// the next batch, which at-least-once delivery allows.
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	leader, err := r.lock.TryAcquire(ctx)
+	if err != nil {
+		r.setLeader(false)
+		return 0, fmt.Errorf("failed to acquire outbox lock: %w", err)
+	}
+	r.setLeader(leader)
+	if !leader {
+		r.logger.Debug("Another relay holds the outbox lock, skipping poll")
+		return 0, nil
+	}
+
+	events, err := r.eventRepo.ListUnpublished(r.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list unpublished events: %w", err)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, r.publishTimeout)
+	published, failed, publishErr := r.publishEvents(publishCtx, events)
+	cancel()
+
+	// Record what was delivered even when shutting down, so it is not sent again
+	err = r.transactor.WithinTransaction(context.WithoutCancel(ctx), func(repos repositories.Repositories) error {
+		ids := make([]uuid.UUID, 0, published)
+		for _, event := range events[:published] {
+			ids = append(ids, event.ID)
+		}
+		if err := repos.Events.MarkPublishedBatch(ids, time.Now()); err != nil {
+			return fmt.Errorf("failed to mark events as published: %w", err)
+		}
+
+		if failed == nil {
+			return nil
+		}
+		if undeliverable(publishErr) && failed.Attempts+1 >= r.maxAttempts {
+			r.logger.WithError(publishErr).WithFields(logrus.Fields{
+				"event_id":   failed.ID.String(),
+				"event_type": failed.Type,
+				"attempts":   failed.Attempts + 1,
+			}).Error("Parking undeliverable outbox event")
+			return repos.Events.MarkFailed(failed.ID, publishErr.Error(), time.Now())
+		}
+		return repos.Events.RecordFailure(failed.ID, publishErr.Error())
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if r.metrics != nil {
+		r.metrics.published.Add(float64(published))
+	}
+	return published, publishErr
+}
+
+// publishEvents sends the events in order and returns how many leading events
+// were delivered, plus the first event that was not and why.
+func (r *Relay) publishEvents(ctx context.Context, events []*entities.Event) (int, *entities.Event, error) {
+	// An undecodable row stops the batch there, keeping the order intact
+	envelopes := make([]producer.Envelope, 0, len(events))
+	var stopErr error
+	for _, event := range events {
+		message, err := Decode(event)
+		if err != nil {
+			stopErr = fmt.Errorf("%w: %w", errUndecodable, err)
+			break
+		}
+		envelopes = append(envelopes, producer.Envelope{
+			Headers: types.Headers{
+				ID:               event.ID,
+				Timestamp:        fmt.Sprint(event.CreatedAt.Unix()),
+				AggregateID:      event.AggregateID,
+				AggregateVersion: event.AggregateVersion,
+			},
+			Event: message,
+		})
+	}
+
+	err := r.producer.PublishBatch(ctx, envelopes)
+	var envelopeErr *producer.EnvelopeError
+	if errors.As(err, &envelopeErr) {
+		// Nothing was written: send the events ahead of the one at fault and
+		// stop there, as for an undecodable row
+		envelopes, stopErr = envelopes[:envelopeErr.Index], envelopeErr
+		err = r.producer.PublishBatch(ctx, envelopes)
+	}
+
+	delivered := len(envelopes)
+	if err != nil {
+		delivered = 0
+		var writeErrs kafka.WriteErrors
+		if errors.As(err, &writeErrs) {
+			for delivered < len(writeErrs) && writeErrs[delivered] == nil {
+				delivered++
+			}
+			if delivered < len(writeErrs) {
+				err = writeErrs[delivered]
+			}
+		}
+	} else {
+		err = stopErr
+	}
+
+	if delivered == len(events) {
+		return delivered, nil, nil
+	}
+
+	failed := events[delivered]
+	if r.metrics != nil {
+		r.metrics.publishFailure.Inc()
+	}
+	r.logger.WithError(err).WithFields(logrus.Fields{
+		"event_id":   failed.ID.String(),
+		"event_type": failed.Type,
+	}).Warn("Failed to publish outbox event")
+	return delivered, failed, err
+}
+
+// undeliverable reports whether an error is caused by the event itself rather
+// than the broker, so retrying it is unlikely to ever succeed.
+func undeliverable(err error) bool {
+	var envelopeErr *producer.EnvelopeError
+	return errors.Is(err, errUndecodable) ||
+		errors.As(err, &envelopeErr) ||
+		errors.Is(err, kafka.MessageSizeTooLarge) ||
+		errors.Is(err, kafka.InvalidMessage) ||
+		errors.Is(err, kafka.InvalidRecord)
+}
+
+// releaseLock lets another relay take over right away on shutdown.
+func (r *Relay) releaseLock() {
+	if err := r.lock.Release(); err != nil {
+		r.logger.WithError(err).Warn("Failed to release outbox lock")
+	}
+	r.setLeader(false)
+}
+
+func (r *Relay) setLeader(leader bool) {
+	if r.metrics == nil {
+		return
+	}
+	if leader {
+		r.metrics.leader.Set(1)
+	} else {
+		r.metrics.leader.Set(0)
+	}
+}
+
+func (r *Relay) reportBacklog() {
+	if r.metrics == nil {
+		return
+	}
+
+	stats, err := r.eventRepo.PendingStats()
+	if err != nil {
+		r.logger.WithError(err).Warn("Failed to read outbox backlog")
+		return
+	}
+
+	r.metrics.backlog.Set(float64(stats.Count))
+	r.metrics.parked.Set(float64(stats.Parked))
+	if stats.Oldest != nil {
+		r.metrics.oldestUnsent.Set(time.Since(*stats.Oldest).Seconds())
+	} else {
+		r.metrics.oldestUnsent.Set(0)
+	}
+}
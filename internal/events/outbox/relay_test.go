@@ -0,0 +1,331 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kitamersion/go-goservice/internal/config"
+	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"github.com/kitamersion/go-goservice/internal/domain/repositories"
+	"github.com/kitamersion/go-goservice/internal/events/producer"
+	"github.com/kitamersion/go-goservice/internal/events/proto/events/userpb"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+const testMaxAttempts = 3
+
+// fakeOutbox keeps outbox rows in memory, in the order they were written.
+type fakeOutbox struct {
+	repositories.EventRepository
+	events []*entities.Event
+}
+
+func (f *fakeOutbox) ListUnpublished(limit int) ([]*entities.Event, error) {
+	var events []*entities.Event
+	for _, event := range f.events {
+		if event.PublishedAt == nil && event.FailedAt == nil && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (f *fakeOutbox) MarkPublishedBatch(ids []uuid.UUID, publishedAt time.Time) error {
+	for _, id := range ids {
+		f.byID(id).PublishedAt = &publishedAt
+	}
+	return nil
+}
+
+func (f *fakeOutbox) RecordFailure(id uuid.UUID, reason string) error {
+	event := f.byID(id)
+	event.Attempts++
+	event.LastError = reason
+	return nil
+}
+
+func (f *fakeOutbox) MarkFailed(id uuid.UUID, reason string, failedAt time.Time) error {
+	event := f.byID(id)
+	event.Attempts++
+	event.LastError = reason
+	event.FailedAt = &failedAt
+	return nil
+}
+
+func (f *fakeOutbox) PendingStats() (*repositories.PendingStats, error) {
+	events, _ := f.ListUnpublished(len(f.events))
+	return &repositories.PendingStats{Count: int64(len(events))}, nil
+}
+
+func (f *fakeOutbox) byID(id uuid.UUID) *entities.Event {
+	for _, event := range f.events {
+		if event.ID == id {
+			return event
+		}
+	}
+	panic("unknown event " + id.String())
+}
+
+type fakeTransactor struct {
+	outbox *fakeOutbox
+}
+
+func (f *fakeTransactor) WithinTransaction(_ context.Context, fn func(repos repositories.Repositories) error) error {
+	return fn(repositories.Repositories{Events: f.outbox})
+}
+
+// fakePublisher answers each PublishBatch call with the next error of errs,
+// and nil once they run out.
+type fakePublisher struct {
+	errs  []error
+	calls [][]producer.Envelope
+}
+
+func (f *fakePublisher) PublishBatch(_ context.Context, envelopes []producer.Envelope) error {
+	f.calls = append(f.calls, envelopes)
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+// fakeLock reports the next result of held on each TryAcquire.
+type fakeLock struct {
+	held     []bool
+	released bool
+}
+
+func (f *fakeLock) TryAcquire(context.Context) (bool, error) {
+	if len(f.held) == 0 {
+		return false, errors.New("no lock result left")
+	}
+	held := f.held[0]
+	f.held = f.held[1:]
+	return held, nil
+}
+
+func (f *fakeLock) Release() error {
+	f.released = true
+	return nil
+}
+
+func testEvent(t *testing.T) *entities.Event {
+	t.Helper()
+	event, err := NewEvent(uuid.NewString(), &userpb.UserCreated{Name: "Jane", Email: "jane@example.com"})
+	if err != nil {
+		t.Fatalf("failed to create event: %v", err)
+	}
+	return event
+}
+
+func newTestRelay(events []*entities.Event, publisher *fakePublisher, lock *fakeLock) (*Relay, *fakeOutbox) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	outbox := &fakeOutbox{events: events}
+	cfg := &config.OutboxConfig{BatchSize: 10, MaxAttempts: testMaxAttempts}
+	return NewRelay(outbox, &fakeTransactor{outbox: outbox}, publisher, lock, cfg, logger), outbox
+}
+
+func TestRelayBatch(t *testing.T) {
+	errBroker := kafka.LeaderNotAvailable
+
+	tests := []struct {
+		name         string
+		undecodable  int     // index of an event with an unknown type, -1 for none
+		attempts     int     // earlier attempts of every event
+		errs         []error // returned by successive PublishBatch calls
+		wantSent     int     // leading events marked published
+		wantAttempts int     // attempts of the first event not sent
+		wantParked   bool
+		wantCalls    int
+	}{
+		{
+			name:        "all delivered",
+			undecodable: -1,
+			wantSent:    4,
+			wantCalls:   1,
+		},
+		{
+			name:         "only the delivered prefix is marked",
+			undecodable:  -1,
+			errs:         []error{kafka.WriteErrors{nil, nil, errBroker, nil}},
+			wantSent:     2,
+			wantAttempts: 1,
+			wantCalls:    1,
+		},
+		{
+			name:         "broker outage is never parked",
+			undecodable:  -1,
+			attempts:     testMaxAttempts,
+			errs:         []error{kafka.WriteErrors{errBroker, errBroker, errBroker, errBroker}},
+			wantAttempts: testMaxAttempts + 1,
+			wantCalls:    1,
+		},
+		{
+			name:         "plain error blames the head",
+			undecodable:  -1,
+			errs:         []error{context.DeadlineExceeded},
+			wantAttempts: 1,
+			wantCalls:    1,
+		},
+		{
+			name:         "undecodable event stops the batch",
+			undecodable:  2,
+			wantSent:     2,
+			wantAttempts: 1,
+			wantCalls:    1,
+		},
+		{
+			name:         "undecodable event is parked after max attempts",
+			undecodable:  2,
+			attempts:     testMaxAttempts - 1,
+			wantSent:     2,
+			wantAttempts: testMaxAttempts,
+			wantParked:   true,
+			wantCalls:    1,
+		},
+		{
+			name:         "oversized event is parked after max attempts",
+			undecodable:  -1,
+			attempts:     testMaxAttempts - 1,
+			errs:         []error{kafka.WriteErrors{nil, kafka.MessageSizeTooLarge, nil, nil}},
+			wantSent:     1,
+			wantAttempts: testMaxAttempts,
+			wantParked:   true,
+			wantCalls:    1,
+		},
+		{
+			name:         "envelope error is blamed on its event",
+			undecodable:  -1,
+			errs:         []error{&producer.EnvelopeError{Index: 2, Err: errors.New("cannot serialize")}},
+			wantSent:     2,
+			wantAttempts: 1,
+			wantCalls:    2,
+		},
+		{
+			name:         "envelope error parks its event, not the head",
+			undecodable:  -1,
+			attempts:     testMaxAttempts - 1,
+			errs:         []error{&producer.EnvelopeError{Index: 1, Err: kafka.MessageTooLargeError{}}},
+			wantSent:     1,
+			wantAttempts: testMaxAttempts,
+			wantParked:   true,
+			wantCalls:    2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := make([]*entities.Event, 4)
+			for i := range events {
+				events[i] = testEvent(t)
+				events[i].Attempts = tt.attempts
+			}
+			if tt.undecodable >= 0 {
+				events[tt.undecodable].Type = "test.Unknown"
+			}
+
+			publisher := &fakePublisher{errs: tt.errs}
+			relay, _ := newTestRelay(events, publisher, &fakeLock{held: []bool{true}})
+
+			sent, err := relay.relayBatch(context.Background())
+			if sent != tt.wantSent {
+				t.Errorf("relayBatch() sent %d, want %d", sent, tt.wantSent)
+			}
+			if (err != nil) != (tt.wantSent < len(events)) {
+				t.Errorf("relayBatch() error = %v", err)
+			}
+			if len(publisher.calls) != tt.wantCalls {
+				t.Errorf("PublishBatch called %d times, want %d", len(publisher.calls), tt.wantCalls)
+			}
+
+			for i, event := range events {
+				if published := event.PublishedAt != nil; published != (i < tt.wantSent) {
+					t.Errorf("event %d published = %v, want %v", i, published, i < tt.wantSent)
+				}
+				wantAttempts := tt.attempts
+				if i == tt.wantSent {
+					wantAttempts = tt.wantAttempts
+				}
+				if event.Attempts != wantAttempts {
+					t.Errorf("event %d attempts = %d, want %d", i, event.Attempts, wantAttempts)
+				}
+				if parked := event.FailedAt != nil; parked != (tt.wantParked && i == tt.wantSent) {
+					t.Errorf("event %d parked = %v", i, parked)
+				}
+			}
+		})
+	}
+}
+
+func TestRelayParkedEventUnblocksTheOutbox(t *testing.T) {
+	events := []*entities.Event{testEvent(t), testEvent(t), testEvent(t)}
+	events[0].Type = "test.Unknown"
+
+	publisher := &fakePublisher{}
+	relay, _ := newTestRelay(events, publisher, &fakeLock{held: []bool{true, true, true, true}})
+
+	for poll := 1; poll <= testMaxAttempts; poll++ {
+		if sent, _ := relay.relayBatch(context.Background()); sent != 0 {
+			t.Fatalf("poll %d sent %d events behind an undecodable head", poll, sent)
+		}
+	}
+	if events[0].FailedAt == nil {
+		t.Fatalf("head was not parked after %d attempts", testMaxAttempts)
+	}
+
+	sent, err := relay.relayBatch(context.Background())
+	if err != nil || sent != 2 {
+		t.Fatalf("relayBatch() after parking = %d, %v; want 2, nil", sent, err)
+	}
+}
+
+func TestRelayLockLoss(t *testing.T) {
+	events := []*entities.Event{testEvent(t), testEvent(t)}
+	publisher := &fakePublisher{}
+	lock := &fakeLock{held: []bool{true, false}}
+	relay, outbox := newTestRelay(events[:1], publisher, lock)
+
+	if sent, err := relay.relayBatch(context.Background()); sent != 1 || err != nil {
+		t.Fatalf("relayBatch() as leader = %d, %v; want 1, nil", sent, err)
+	}
+
+	// Another relay took the lock; this one must leave new events alone
+	outbox.events = append(outbox.events, events[1])
+	if sent, err := relay.relayBatch(context.Background()); sent != 0 || err != nil {
+		t.Fatalf("relayBatch() after losing the lock = %d, %v; want 0, nil", sent, err)
+	}
+	if len(publisher.calls) != 1 || events[1].PublishedAt != nil {
+		t.Fatal("relay published after losing the lock")
+	}
+
+	// Failing to check the lock counts as not holding it
+	if sent, err := relay.relayBatch(context.Background()); sent != 0 || err == nil {
+		t.Fatalf("relayBatch() when the lock check fails = %d, %v; want 0 and an error", sent, err)
+	}
+	if len(publisher.calls) != 1 {
+		t.Fatal("relay published without confirming the lock")
+	}
+}
+
+func TestRelayReleasesLockOnShutdown(t *testing.T) {
+	lock := &fakeLock{}
+	relay, _ := newTestRelay(nil, &fakePublisher{}, lock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := relay.Start(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Start() = %v, want context.Canceled", err)
+	}
+	if !lock.released {
+		t.Fatal("lock was not released on shutdown")
+	}
+}
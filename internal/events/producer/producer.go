@@ -1,81 +1,224 @@
 package producer
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/kitamersion/go-goservice/internal/config"
+	"github.com/kitamersion/go-goservice/internal/events/codec"
+	"github.com/kitamersion/go-goservice/internal/events/schema"
 	"github.com/kitamersion/go-goservice/internal/events/types"
 	"github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
-	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 )
 
 type Producer struct {
-	writer *kafka.Writer
-	logger *logrus.Logger
+	writer       *kafka.Writer // single events, flushed immediately
+	batchWriter  *kafka.Writer // PublishBatch, many events per request
+	contentType  string
+	headerFormat string
+	source       string
+	schemas      *schema.Registry
+	unknown      sync.Map // event types already reported as unregistered
+	logger       *logrus.Logger
 }
 
-func NewProducer(cfg *config.KafkaConfig, logger *logrus.Logger) *Producer {
-	writer := &kafka.Writer{
-		Addr:         kafka.TCP(cfg.Brokers...),
-		Topic:        cfg.Topics.UserEvents,
-		Balancer:     &kafka.LeastBytes{},
-		RequiredAcks: kafka.RequireAll, // Strong durability
-		BatchSize:    1,                // default is fine, or increase for higher throughput
-		BatchTimeout: 10 * time.Millisecond,
-	}
+type Option func(*Producer)
 
-	return &Producer{
-		writer: writer,
-		logger: logger,
+// WithSchemaRegistry stamps every message with the registered ID of its schema.
+func WithSchemaRegistry(registry *schema.Registry) Option {
+	return func(p *Producer) {
+		p.schemas = registry
 	}
 }
 
-func (p *Producer) PublishEvent(ctx context.Context, protoEvent proto.Message) error {
-	// Serialize the proto event to JSON
-	serializedEvent, err := protojson.Marshal(protoEvent)
+func NewProducer(cfg *config.KafkaConfig, logger *logrus.Logger, opts ...Option) *Producer {
+	newWriter := func(batchSize int) *kafka.Writer {
+		return &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers...),
+			Topic:        cfg.Topics.UserEvents,
+			Balancer:     &kafka.Hash{},    // same aggregate ID -> same partition
+			RequiredAcks: kafka.RequireAll, // Strong durability
+			BatchSize:    batchSize,
+			BatchTimeout: 10 * time.Millisecond,
+		}
+	}
+
+	contentType, err := codec.Normalize(cfg.ContentTypes[cfg.Topics.UserEvents])
 	if err != nil {
-		p.logger.WithError(err).Error("Failed to serialize proto event")
-		return fmt.Errorf("failed to serialize proto event: %w", err)
+		logger.WithError(err).Warn("Falling back to JSON payloads")
+		contentType = codec.ContentTypeJSON
+	}
+
+	p := &Producer{
+		writer:       newWriter(1), // a full batch of one never waits for BatchTimeout
+		batchWriter:  newWriter(100),
+		contentType:  contentType,
+		headerFormat: cfg.HeaderFormat,
+		source:       cfg.EventSource,
+		logger:       logger,
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
 
+// PublishEvent publishes an event for the given aggregate, which becomes the
+// partition key.
+func (p *Producer) PublishEvent(ctx context.Context, aggregateID string, protoEvent proto.Message) error {
 	headers := types.Headers{
-		ID:        uuid.New(),
-		Timestamp: fmt.Sprint(time.Now().Unix()), // Use Unix timestamp as string for JSON serialization
+		ID:          uuid.New(),
+		Timestamp:   fmt.Sprint(time.Now().Unix()), // Use Unix timestamp as string for JSON serialization
+		AggregateID: aggregateID,
 	}
 
-	// Get the event type from the proto message
-	eventType := string(protoEvent.ProtoReflect().Descriptor().FullName())
+	return p.Publish(ctx, headers, protoEvent)
+}
+
+// Publish writes the event using the supplied headers, so callers such as the
+// outbox relay can keep the same event ID across redeliveries.
+func (p *Producer) Publish(ctx context.Context, headers types.Headers, protoEvent proto.Message) error {
+	return p.write(ctx, p.writer, []Envelope{{Headers: headers, Event: protoEvent}})
+}
+
+// Envelope is one event of a batch together with its headers.
+type Envelope struct {
+	Headers types.Headers
+	Event   proto.Message
+}
+
+// EnvelopeError reports the envelope of a batch that cannot be sent because
+// of its own content, such as a payload that does not serialize or exceeds the
+// broker's message size. None of the batch was written.
+type EnvelopeError struct {
+	Index int
+	Err   error
+}
+
+func (e *EnvelopeError) Error() string {
+	return fmt.Sprintf("event %d of the batch: %v", e.Index, e.Err)
+}
+
+func (e *EnvelopeError) Unwrap() error {
+	return e.Err
+}
+
+// PublishBatch writes all events with a single writer call. When only some of
+// them could not be written the error is a kafka.WriteErrors holding one entry
+// per envelope, in order; an *EnvelopeError names an envelope that prevented
+// the whole batch from being written.
+//
+// Batches go through a writer of their own, which may wait up to its
+// BatchTimeout to fill a request, so single events are never delayed by it.
+func (p *Producer) PublishBatch(ctx context.Context, envelopes []Envelope) error {
+	return p.write(ctx, p.batchWriter, envelopes)
+}
+
+func (p *Producer) write(ctx context.Context, writer *kafka.Writer, envelopes []Envelope) error {
+	if len(envelopes) == 0 {
+		return nil
+	}
 
-	message := kafka.Message{
-		Key: []byte(headers.ID.String()),
-		Headers: []kafka.Header{
-			{Key: "event_id", Value: []byte(headers.ID.String())},
-			{Key: "timestamp", Value: []byte(headers.Timestamp)},
-			{Key: "event_type", Value: []byte(eventType)},
-			{Key: "content_type", Value: []byte("application/json")},
-		},
-		Value: serializedEvent,
+	messages := make([]kafka.Message, 0, len(envelopes))
+	for i := range envelopes {
+		message, err := p.message(&envelopes[i])
+		if err != nil {
+			return &EnvelopeError{Index: i, Err: err}
+		}
+		messages = append(messages, message)
 	}
 
-	err = p.writer.WriteMessages(ctx, message)
+	err := writer.WriteMessages(ctx, messages...)
+	var tooLarge kafka.MessageTooLargeError
+	if errors.As(err, &tooLarge) {
+		err = &EnvelopeError{Index: messageIndex(messages, tooLarge.Message), Err: err}
+	}
 	if err != nil {
 		p.logger.WithError(err).Error("Failed to publish event")
 		return fmt.Errorf("failed to publish event: %w", err)
 	}
 
-	p.logger.WithFields(logrus.Fields{
-		"event_id":   headers.ID.String(),
-		"event_type": eventType,
-	}).Info("Event published successfully")
+	for _, envelope := range envelopes {
+		p.logger.WithFields(logrus.Fields{
+			"event_id":          envelope.Headers.ID.String(),
+			"event_type":        envelope.Headers.EventType,
+			"aggregate_id":      envelope.Headers.AggregateID,
+			"aggregate_version": envelope.Headers.AggregateVersion,
+		}).Info("Event published successfully")
+	}
 
 	return nil
 }
 
+// messageIndex finds the first message with the same key and value as m.
+func messageIndex(messages []kafka.Message, m kafka.Message) int {
+	for i := range messages {
+		if bytes.Equal(messages[i].Key, m.Key) && bytes.Equal(messages[i].Value, m.Value) {
+			return i
+		}
+	}
+	return 0
+}
+
+// message serializes the event in the topic's content type and completes its
+// headers.
+func (p *Producer) message(envelope *Envelope) (kafka.Message, error) {
+	protoEvent := envelope.Event
+	serializedEvent, err := codec.Marshal(p.contentType, protoEvent)
+	if err != nil {
+		p.logger.WithError(err).Error("Failed to serialize proto event")
+		return kafka.Message{}, fmt.Errorf("failed to serialize proto event: %w", err)
+	}
+
+	// Get the event type from the proto message
+	headers := &envelope.Headers
+	headers.EventType = string(protoEvent.ProtoReflect().Descriptor().FullName())
+	headers.ContentType = p.contentType
+	headers.Source = p.source
+	if schemaID, ok := p.schemaID(protoEvent); ok {
+		headers.SchemaID = schemaID
+	}
+
+	key := headers.AggregateID
+	if key == "" {
+		key = headers.ID.String()
+	}
+
+	return kafka.Message{
+		Key:     []byte(key),
+		Headers: headers.ToKafka(p.headerFormat),
+		Value:   serializedEvent,
+	}, nil
+}
+
+// schemaID looks up the registered schema of the event. A missing registration
+// is not fatal: the event is still published, just without a schema_id header.
+func (p *Producer) schemaID(protoEvent proto.Message) (int64, bool) {
+	if p.schemas == nil {
+		return 0, false
+	}
+
+	id, ok, err := p.schemas.SchemaID(protoEvent)
+	if err != nil {
+		p.logger.WithError(err).Warn("Failed to look up event schema")
+		return 0, false
+	}
+	if !ok {
+		eventType := protoEvent.ProtoReflect().Descriptor().FullName()
+		if _, reported := p.unknown.LoadOrStore(eventType, true); !reported {
+			p.logger.WithField("event_type", eventType).Warn("Event schema is not registered")
+		}
+	}
+	return id, ok
+}
+
 func (p *Producer) Close() error {
-	return p.writer.Close()
+	return errors.Join(p.writer.Close(), p.batchWriter.Close())
 }
@@ -0,0 +1,202 @@
+package producer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kitamersion/go-goservice/internal/config"
+	"github.com/kitamersion/go-goservice/internal/events/proto/events/userpb"
+	"github.com/kitamersion/go-goservice/internal/events/types"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+	"github.com/segmentio/kafka-go/protocol/metadata"
+	"github.com/segmentio/kafka-go/protocol/produce"
+	"github.com/sirupsen/logrus"
+)
+
+const testTopic = "user-events"
+
+// producedRecord is what the fake broker keeps of each record.
+type producedRecord struct {
+	key     string
+	version string // aggregate_version header
+}
+
+// fakeBroker answers the metadata and produce requests of a kafka.Writer for
+// a single topic led by a single broker, and records what was produced.
+type fakeBroker struct {
+	partitions int
+
+	mu       sync.Mutex
+	produced map[int][]producedRecord // by partition, in the order written
+}
+
+var _ kafka.RoundTripper = (*fakeBroker)(nil)
+
+func (b *fakeBroker) RoundTrip(_ context.Context, _ net.Addr, req protocol.Message) (protocol.Message, error) {
+	switch req := req.(type) {
+	case *metadata.Request:
+		topic := metadata.ResponseTopic{Name: testTopic}
+		for i := 0; i < b.partitions; i++ {
+			topic.Partitions = append(topic.Partitions, metadata.ResponsePartition{PartitionIndex: int32(i), LeaderID: 1})
+		}
+		return &metadata.Response{
+			Brokers: []metadata.ResponseBroker{{NodeID: 1, Host: "broker", Port: 9092}},
+			Topics:  []metadata.ResponseTopic{topic},
+		}, nil
+
+	case *produce.Request:
+		res := &produce.Response{}
+		for _, topic := range req.Topics {
+			resTopic := produce.ResponseTopic{Topic: topic.Topic}
+			for _, partition := range topic.Partitions {
+				offset, err := b.store(int(partition.Partition), partition.RecordSet.Records)
+				if err != nil {
+					return nil, err
+				}
+				resTopic.Partitions = append(resTopic.Partitions, produce.ResponsePartition{Partition: partition.Partition, BaseOffset: offset})
+			}
+			res.Topics = append(res.Topics, resTopic)
+		}
+		return res, nil
+
+	default:
+		return nil, fmt.Errorf("unexpected request %T", req)
+	}
+}
+
+// store appends the records to the partition and returns the offset of the
+// first one.
+func (b *fakeBroker) store(partition int, records protocol.RecordReader) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	base := int64(len(b.produced[partition]))
+	for {
+		record, err := records.ReadRecord()
+		if err == io.EOF {
+			return base, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		key, err := protocol.ReadAll(record.Key)
+		if err != nil {
+			return 0, err
+		}
+		produced := producedRecord{key: string(key)}
+		for _, header := range record.Headers {
+			if header.Key == types.HeaderAggregateVersion {
+				produced.version = string(header.Value)
+			}
+		}
+		b.produced[partition] = append(b.produced[partition], produced)
+	}
+}
+
+func newTestProducer(broker *fakeBroker) *Producer {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := &config.KafkaConfig{Brokers: []string{"broker:9092"}}
+	cfg.Topics.UserEvents = testTopic
+	p := NewProducer(cfg, logger)
+	p.writer.Transport = broker
+	p.batchWriter.Transport = broker
+	return p
+}
+
+func testEnvelope(aggregateID string, version int64) Envelope {
+	return Envelope{
+		Headers: types.Headers{
+			ID:               uuid.New(),
+			Timestamp:        strconv.FormatInt(time.Now().Unix(), 10),
+			AggregateID:      aggregateID,
+			AggregateVersion: version,
+		},
+		Event: &userpb.UserUpdated{Id: aggregateID, UpdatedAt: time.Now().Unix()},
+	}
+}
+
+func TestPublishFlushesSingleEvents(t *testing.T) {
+	broker := &fakeBroker{partitions: 3, produced: make(map[int][]producedRecord)}
+	p := newTestProducer(broker)
+	defer p.Close()
+
+	// A writer waiting for its batch to fill would hold the event for a minute
+	p.writer.BatchTimeout = time.Minute
+	p.batchWriter.BatchTimeout = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 1; i <= 3; i++ {
+		envelope := testEnvelope("user-1", int64(i))
+		if err := p.Publish(ctx, envelope.Headers, envelope.Event); err != nil {
+			t.Fatalf("Publish() of event %d = %v", i, err)
+		}
+	}
+
+	total := 0
+	for _, records := range broker.produced {
+		total += len(records)
+	}
+	if total != 3 {
+		t.Fatalf("broker received %d events, want 3", total)
+	}
+}
+
+func TestPublishBatchKeepsOrderPerKey(t *testing.T) {
+	broker := &fakeBroker{partitions: 4, produced: make(map[int][]producedRecord)}
+	p := newTestProducer(broker)
+	defer p.Close()
+
+	keys := []string{"user-a", "user-b", "user-c", "user-d", "user-e"}
+	const versions = 4
+
+	// Interleave the keys, as events of unrelated users are in the outbox
+	var envelopes []Envelope
+	for version := int64(1); version <= versions; version++ {
+		for _, key := range keys {
+			envelopes = append(envelopes, testEnvelope(key, version))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.PublishBatch(ctx, envelopes); err != nil {
+		t.Fatalf("PublishBatch() = %v", err)
+	}
+
+	partitionOf := make(map[string]int)
+	seen := make(map[string][]string)
+	for partition, records := range broker.produced {
+		for _, record := range records {
+			if other, ok := partitionOf[record.key]; ok && other != partition {
+				t.Fatalf("%s was written to partitions %d and %d", record.key, other, partition)
+			}
+			partitionOf[record.key] = partition
+			seen[record.key] = append(seen[record.key], record.version)
+		}
+	}
+
+	for _, key := range keys {
+		if len(seen[key]) != versions {
+			t.Fatalf("%s: broker received versions %v, want %d of them", key, seen[key], versions)
+		}
+		for i, version := range seen[key] {
+			if want := strconv.Itoa(i + 1); version != want {
+				t.Fatalf("%s: versions arrived as %v, want them in order", key, seen[key])
+			}
+		}
+	}
+	if len(broker.produced) < 2 {
+		t.Errorf("every key went to the same partition; the test does not exercise the balancer")
+	}
+}
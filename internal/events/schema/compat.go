@@ -0,0 +1,180 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Compatibility is the guarantee a new schema version has to keep towards the
+// registered versions.
+type Compatibility string
+
+const (
+	// Backward: consumers on the new schema can read events written with the old ones.
+	Backward Compatibility = "backward"
+	// Forward: consumers still on an old schema can read events written with the new one.
+	Forward Compatibility = "forward"
+	// Full: both backward and forward.
+	Full Compatibility = "full"
+	// None disables compatibility checks.
+	None Compatibility = "none"
+)
+
+func ParseCompatibility(s string) (Compatibility, error) {
+	switch c := Compatibility(strings.ToLower(strings.TrimSpace(s))); c {
+	case Backward, Forward, Full, None:
+		return c, nil
+	case "":
+		return Full, nil
+	default:
+		return "", fmt.Errorf("unknown compatibility mode %q", s)
+	}
+}
+
+// Issue is a single incompatibility between two schema versions.
+type Issue struct {
+	Path     string
+	Message  string
+	Breaks   Compatibility // Backward, Forward or Full
+	Previous int           // registered version the issue was found against
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s (breaks %s compatibility with version %d)", i.Path, i.Message, i.Breaks, i.Previous)
+}
+
+// applies reports whether the issue violates the given mode.
+func (i Issue) applies(mode Compatibility) bool {
+	switch mode {
+	case None:
+		return false
+	case Full:
+		return true
+	default:
+		return i.Breaks == Full || i.Breaks == mode
+	}
+}
+
+// Compare lists the changes from previous to next that break wire or JSON
+// compatibility. Payloads may be encoded as binary protobuf or protojson, so
+// field names matter as much as field numbers.
+func Compare(previous, next protoreflect.MessageDescriptor) []Issue {
+	c := comparer{visited: make(map[protoreflect.FullName]bool)}
+	c.messages(string(next.FullName()), previous, next)
+	return c.issues
+}
+
+type comparer struct {
+	issues  []Issue
+	visited map[protoreflect.FullName]bool
+}
+
+func (c *comparer) add(path string, breaks Compatibility, format string, args ...interface{}) {
+	c.issues = append(c.issues, Issue{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+		Breaks:  breaks,
+	})
+}
+
+func (c *comparer) messages(path string, previous, next protoreflect.MessageDescriptor) {
+	if c.visited[previous.FullName()] {
+		return
+	}
+	c.visited[previous.FullName()] = true
+
+	previousFields := previous.Fields()
+	nextFields := next.Fields()
+
+	for i := 0; i < previousFields.Len(); i++ {
+		old := previousFields.Get(i)
+		fieldPath := path + "." + string(old.Name())
+
+		updated := nextFields.ByNumber(old.Number())
+		if updated == nil {
+			if renamed := nextFields.ByName(old.Name()); renamed != nil {
+				c.add(fieldPath, Full, "field number changed from %d to %d", old.Number(), renamed.Number())
+			} else if !next.ReservedRanges().Has(old.Number()) {
+				c.add(fieldPath, Forward, "field %d removed without reserving its number", old.Number())
+			}
+			continue
+		}
+		c.fields(fieldPath, old, updated)
+	}
+
+	for i := 0; i < nextFields.Len(); i++ {
+		added := nextFields.Get(i)
+		if previousFields.ByNumber(added.Number()) != nil {
+			continue
+		}
+		fieldPath := path + "." + string(added.Name())
+		if previous.ReservedRanges().Has(added.Number()) {
+			c.add(fieldPath, Full, "field reuses reserved number %d", added.Number())
+		}
+		if previous.ReservedNames().Has(added.Name()) {
+			c.add(fieldPath, Full, "field reuses reserved name %q", added.Name())
+		}
+		if added.Cardinality() == protoreflect.Required {
+			c.add(fieldPath, Backward, "required field added")
+		}
+	}
+}
+
+func (c *comparer) fields(path string, previous, next protoreflect.FieldDescriptor) {
+	if previous.Name() != next.Name() {
+		c.add(path, Full, "field %d renamed from %q to %q", previous.Number(), previous.Name(), next.Name())
+	}
+	if previous.JSONName() != next.JSONName() {
+		c.add(path, Full, "JSON name changed from %q to %q", previous.JSONName(), next.JSONName())
+	}
+	if previous.Kind() != next.Kind() {
+		c.add(path, Full, "type changed from %s to %s", previous.Kind(), next.Kind())
+		return
+	}
+	if previous.IsList() != next.IsList() || previous.IsMap() != next.IsMap() {
+		c.add(path, Full, "cardinality changed")
+		return
+	}
+
+	switch previous.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if previous.Message().FullName() != next.Message().FullName() {
+			c.add(path, Full, "message type changed from %s to %s", previous.Message().FullName(), next.Message().FullName())
+			return
+		}
+		c.messages(path, previous.Message(), next.Message())
+	case protoreflect.EnumKind:
+		if previous.Enum().FullName() != next.Enum().FullName() {
+			c.add(path, Full, "enum type changed from %s to %s", previous.Enum().FullName(), next.Enum().FullName())
+			return
+		}
+		c.enums(path, previous.Enum(), next.Enum())
+	}
+}
+
+func (c *comparer) enums(path string, previous, next protoreflect.EnumDescriptor) {
+	previousValues := previous.Values()
+	nextValues := next.Values()
+
+	for i := 0; i < previousValues.Len(); i++ {
+		old := previousValues.Get(i)
+		updated := nextValues.ByNumber(old.Number())
+		switch {
+		case updated == nil:
+			// New readers fail to decode the old name from JSON
+			c.add(path, Backward, "enum value %s (%d) removed", old.Name(), old.Number())
+		case updated.Name() != old.Name():
+			c.add(path, Full, "enum value %d renamed from %s to %s", old.Number(), old.Name(), updated.Name())
+		}
+	}
+
+	for i := 0; i < nextValues.Len(); i++ {
+		added := nextValues.Get(i)
+		if previousValues.ByNumber(added.Number()) == nil {
+			// Old readers fail to decode the new name from JSON
+			c.add(path, Forward, "enum value %s (%d) added", added.Name(), added.Number())
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// testMessage builds a proto3 message test.User with the given fields and
+// reserved numbers and names.
+func testMessage(t *testing.T, fields []*descriptorpb.FieldDescriptorProto, reservedNumbers []int32, reservedNames ...string) protoreflect.MessageDescriptor {
+	t.Helper()
+
+	message := &descriptorpb.DescriptorProto{
+		Name:         proto.String("User"),
+		Field:        fields,
+		ReservedName: reservedNames,
+	}
+	for _, number := range reservedNumbers {
+		message.ReservedRange = append(message.ReservedRange, &descriptorpb.DescriptorProto_ReservedRange{
+			Start: proto.Int32(number),
+			End:   proto.Int32(number + 1),
+		})
+	}
+
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        proto.String("test/user.proto"),
+		Package:     proto.String("test"),
+		Syntax:      proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{message},
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to build descriptor: %v", err)
+	}
+	return file.Messages().ByName("User")
+}
+
+func testField(name string, number int32, kind descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(number),
+		Type:   kind.Enum(),
+		Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+	}
+}
+
+const (
+	typeString = descriptorpb.FieldDescriptorProto_TYPE_STRING
+	typeInt64  = descriptorpb.FieldDescriptorProto_TYPE_INT64
+)
+
+func TestCompare(t *testing.T) {
+	previousFields := []*descriptorpb.FieldDescriptorProto{
+		testField("id", 1, typeString),
+		testField("email", 2, typeString),
+		testField("name", 3, typeString),
+	}
+
+	tests := []struct {
+		name   string
+		prev   protoreflect.MessageDescriptor
+		next   protoreflect.MessageDescriptor
+		want   []Issue // compared on Path, Breaks and a Message substring
+		modeOK []Compatibility
+	}{
+		{
+			name:   "unchanged",
+			prev:   testMessage(t, previousFields, nil),
+			next:   testMessage(t, previousFields, nil),
+			modeOK: []Compatibility{Backward, Forward, Full},
+		},
+		{
+			name: "field added",
+			prev: testMessage(t, previousFields, nil),
+			next: testMessage(t, append(previousFields[:3:3],
+				testField("nickname", 4, typeString),
+			), nil),
+			modeOK: []Compatibility{Backward, Forward, Full},
+		},
+		{
+			name: "field removed without reserving it",
+			prev: testMessage(t, previousFields, nil),
+			next: testMessage(t, previousFields[:2], nil),
+			want: []Issue{
+				{Path: "test.User.name", Breaks: Forward, Message: "field 3 removed without reserving its number"},
+			},
+			modeOK: []Compatibility{Backward},
+		},
+		{
+			name:   "field removed and reserved",
+			prev:   testMessage(t, previousFields, nil),
+			next:   testMessage(t, previousFields[:2], []int32{3}, "name"),
+			modeOK: []Compatibility{Backward, Forward, Full},
+		},
+		{
+			name: "field type changed",
+			prev: testMessage(t, previousFields, nil),
+			next: testMessage(t, []*descriptorpb.FieldDescriptorProto{
+				testField("id", 1, typeString),
+				testField("email", 2, typeString),
+				testField("name", 3, typeInt64),
+			}, nil),
+			want: []Issue{
+				{Path: "test.User.name", Breaks: Full, Message: "type changed from string to int64"},
+			},
+		},
+		{
+			name: "field renumbered",
+			prev: testMessage(t, previousFields, nil),
+			next: testMessage(t, []*descriptorpb.FieldDescriptorProto{
+				testField("id", 1, typeString),
+				testField("email", 2, typeString),
+				testField("name", 4, typeString),
+			}, nil),
+			want: []Issue{
+				{Path: "test.User.name", Breaks: Full, Message: "field number changed from 3 to 4"},
+			},
+		},
+		{
+			name: "reserved number reused",
+			prev: testMessage(t, previousFields[:2], []int32{3}),
+			next: testMessage(t, append(previousFields[:2:2],
+				testField("nickname", 3, typeString),
+			), nil),
+			want: []Issue{
+				{Path: "test.User.nickname", Breaks: Full, Message: "reuses reserved number 3"},
+			},
+		},
+		{
+			name: "reserved name reused",
+			prev: testMessage(t, previousFields[:2], []int32{3}, "name"),
+			next: testMessage(t, append(previousFields[:2:2],
+				testField("name", 4, typeString),
+			), []int32{3}),
+			want: []Issue{
+				{Path: "test.User.name", Breaks: Full, Message: `reuses reserved name "name"`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := Compare(tt.prev, tt.next)
+
+			if len(issues) != len(tt.want) {
+				t.Fatalf("got %d issues %v, want %d", len(issues), issues, len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := issues[i]
+				if got.Path != want.Path || got.Breaks != want.Breaks || !strings.Contains(got.Message, want.Message) {
+					t.Errorf("issue %d = %s, want %s: %s (breaks %s)", i, got, want.Path, want.Message, want.Breaks)
+				}
+			}
+
+			for _, mode := range []Compatibility{Backward, Forward, Full} {
+				wantOK := false
+				for _, ok := range tt.modeOK {
+					wantOK = wantOK || ok == mode
+				}
+				violated := false
+				for _, issue := range issues {
+					violated = violated || issue.applies(mode)
+				}
+				if violated == wantOK {
+					t.Errorf("%s compatibility: violated = %v, want %v", mode, violated, !wantOK)
+				}
+			}
+		})
+	}
+}
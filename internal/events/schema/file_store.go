@@ -0,0 +1,125 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kitamersion/go-goservice/internal/domain/entities"
+)
+
+// FileStore keeps the registry in a JSON file that can be committed next to
+// the proto files.
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+type registryFile struct {
+	Schemas []*entities.SchemaVersion `json:"schemas"`
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{
+		path: path,
+	}
+}
+
+func (s *FileStore) List(subject string) ([]*entities.SchemaVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var schemas []*entities.SchemaVersion
+	for _, schema := range file.Schemas {
+		if schema.Subject == subject {
+			schemas = append(schemas, schema)
+		}
+	}
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Version < schemas[j].Version })
+	return schemas, nil
+}
+
+func (s *FileStore) GetByFingerprint(subject, fingerprint string) (*entities.SchemaVersion, error) {
+	schemas, err := s.List(subject)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(schemas) - 1; i >= 0; i-- {
+		if schemas[i].Fingerprint == fingerprint {
+			return schemas[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *FileStore) Create(schema *entities.SchemaVersion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	var lastID int64
+	version := 0
+	for _, existing := range file.Schemas {
+		if existing.ID > lastID {
+			lastID = existing.ID
+		}
+		if existing.Subject == schema.Subject && existing.Version > version {
+			version = existing.Version
+		}
+	}
+	schema.ID = lastID + 1
+	schema.Version = version + 1
+	if schema.CreatedAt.IsZero() {
+		schema.CreatedAt = time.Now().UTC()
+	}
+
+	file.Schemas = append(file.Schemas, schema)
+	return s.save(file)
+}
+
+func (s *FileStore) load() (*registryFile, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &registryFile{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema registry %s: %w", s.path, err)
+	}
+
+	var file registryFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse schema registry %s: %w", s.path, err)
+	}
+	return &file, nil
+}
+
+// save writes through a temporary file so a crash never leaves a truncated registry.
+func (s *FileStore) save(file *registryFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,76 @@
+package schema
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/kitamersion/go-goservice/internal/config"
+	"github.com/kitamersion/go-goservice/internal/domain/repositories"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"gorm.io/gorm"
+)
+
+// NewStore picks the store configured under schema_registry. db may be nil for
+// the file store.
+func NewStore(cfg *config.SchemaConfig, db *gorm.DB) (Store, error) {
+	switch cfg.Store {
+	case "", "file":
+		return NewFileStore(cfg.Path), nil
+	case "postgres":
+		if db == nil {
+			return nil, fmt.Errorf("postgres schema store needs a database connection")
+		}
+		return repositories.NewSchemaRepository(db), nil
+	default:
+		return nil, fmt.Errorf("unknown schema store %q", cfg.Store)
+	}
+}
+
+// NewRegistryFromConfig builds the registry configured under schema_registry.
+func NewRegistryFromConfig(cfg *config.SchemaConfig, db *gorm.DB) (*Registry, error) {
+	compatibility, err := ParseCompatibility(cfg.Compatibility)
+	if err != nil {
+		return nil, err
+	}
+	store, err := NewStore(cfg, db)
+	if err != nil {
+		return nil, err
+	}
+	return NewRegistry(store, compatibility), nil
+}
+
+// LoadDescriptorSet reads a FileDescriptorSet as written by
+// `protoc --include_imports --descriptor_set_out`.
+func LoadDescriptorSet(path string) (*protoregistry.Files, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read descriptor set: %w", err)
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse descriptor set: %w", err)
+	}
+	return protodesc.NewFiles(&set)
+}
+
+// Messages returns the top-level messages of the given proto packages, sorted
+// by name.
+func Messages(files *protoregistry.Files, packages []string) []protoreflect.MessageDescriptor {
+	var messages []protoreflect.MessageDescriptor
+	for _, pkg := range packages {
+		files.RangeFilesByPackage(protoreflect.FullName(pkg), func(file protoreflect.FileDescriptor) bool {
+			for i := 0; i < file.Messages().Len(); i++ {
+				messages = append(messages, file.Messages().Get(i))
+			}
+			return true
+		})
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].FullName() < messages[j].FullName() })
+	return messages
+}
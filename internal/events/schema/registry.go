@@ -0,0 +1,182 @@
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// ErrIncompatible is returned when a schema breaks the configured compatibility.
+var ErrIncompatible = errors.New("schema is incompatible with registered versions")
+
+// Store persists schema versions. It is implemented by FileStore and
+// repositories.SchemaRepository.
+type Store interface {
+	List(subject string) ([]*entities.SchemaVersion, error)
+	GetByFingerprint(subject, fingerprint string) (*entities.SchemaVersion, error)
+	Create(schema *entities.SchemaVersion) error
+}
+
+// Registry versions event schemas per message type (the subject is the
+// message's full name, which is also the event_type header).
+type Registry struct {
+	store         Store
+	compatibility Compatibility
+
+	mu  sync.Mutex
+	ids map[protoreflect.FullName]int64 // cached IDs of the compiled-in schemas
+}
+
+func NewRegistry(store Store, compatibility Compatibility) *Registry {
+	return &Registry{
+		store:         store,
+		compatibility: compatibility,
+		ids:           make(map[protoreflect.FullName]int64),
+	}
+}
+
+// Check compares a message schema against every registered version of its
+// subject and returns the issues that violate the registry's compatibility.
+func (r *Registry) Check(desc protoreflect.MessageDescriptor) ([]Issue, error) {
+	registered, err := r.store.List(string(desc.FullName()))
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+	for _, version := range registered {
+		previous, err := messageDescriptor(version)
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range Compare(previous, desc) {
+			if issue.applies(r.compatibility) {
+				issue.Previous = version.Version
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return issues, nil
+}
+
+// Register stores the schema as a new version unless an identical one exists.
+// Incompatible schemas are rejected with ErrIncompatible and the issues found.
+func (r *Registry) Register(desc protoreflect.MessageDescriptor) (*entities.SchemaVersion, []Issue, error) {
+	descriptor, fingerprint, err := descriptorSet(desc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	subject := string(desc.FullName())
+	existing, err := r.store.GetByFingerprint(subject, fingerprint)
+	if err != nil {
+		return nil, nil, err
+	}
+	if existing != nil {
+		return existing, nil, nil
+	}
+
+	issues, err := r.Check(desc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(issues) > 0 {
+		return nil, issues, ErrIncompatible
+	}
+
+	schema := &entities.SchemaVersion{
+		Subject:     subject,
+		Fingerprint: fingerprint,
+		Descriptor:  descriptor,
+	}
+	if err := r.store.Create(schema); err != nil {
+		return nil, nil, fmt.Errorf("failed to store schema %s: %w", subject, err)
+	}
+	return schema, nil, nil
+}
+
+// SchemaID returns the registered ID of the schema the message was compiled
+// with, and false when that exact schema was never registered.
+func (r *Registry) SchemaID(message proto.Message) (int64, bool, error) {
+	desc := message.ProtoReflect().Descriptor()
+
+	r.mu.Lock()
+	id, cached := r.ids[desc.FullName()]
+	r.mu.Unlock()
+	if cached {
+		return id, true, nil
+	}
+
+	_, fingerprint, err := descriptorSet(desc)
+	if err != nil {
+		return 0, false, err
+	}
+	schema, err := r.store.GetByFingerprint(string(desc.FullName()), fingerprint)
+	if err != nil || schema == nil {
+		return 0, false, err
+	}
+
+	r.mu.Lock()
+	r.ids[desc.FullName()] = schema.ID
+	r.mu.Unlock()
+	return schema.ID, true, nil
+}
+
+// descriptorSet serialises the message's file and its imports deterministically
+// and fingerprints the result.
+func descriptorSet(desc protoreflect.MessageDescriptor) ([]byte, string, error) {
+	set := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]bool)
+
+	var add func(file protoreflect.FileDescriptor)
+	add = func(file protoreflect.FileDescriptor) {
+		if seen[file.Path()] {
+			return
+		}
+		seen[file.Path()] = true
+		imports := file.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		fileProto := protodesc.ToFileDescriptorProto(file)
+		fileProto.SourceCodeInfo = nil
+		set.File = append(set.File, fileProto)
+	}
+	add(desc.ParentFile())
+
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(set)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to serialize descriptor for %s: %w", desc.FullName(), err)
+	}
+	sum := sha256.Sum256(data)
+	return data, hex.EncodeToString(sum[:]), nil
+}
+
+// messageDescriptor rebuilds the registered message descriptor.
+func messageDescriptor(schema *entities.SchemaVersion) (protoreflect.MessageDescriptor, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(schema.Descriptor, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse %s version %d: %w", schema.Subject, schema.Version, err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s version %d: %w", schema.Subject, schema.Version, err)
+	}
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(schema.Subject))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find %s in version %d: %w", schema.Subject, schema.Version, err)
+	}
+	message, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message", schema.Subject)
+	}
+	return message, nil
+}
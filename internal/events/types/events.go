@@ -2,15 +2,149 @@ package types
 
 import (
 	"encoding/json"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+)
+
+// Kafka header keys set by the producer.
+const (
+	HeaderEventID          = "event_id"
+	HeaderTimestamp        = "timestamp"
+	HeaderEventType        = "event_type"
+	HeaderContentType      = "content_type"
+	HeaderAggregateID      = "aggregate_id"
+	HeaderAggregateVersion = "aggregate_version"
+	HeaderSchemaID         = "schema_id"
+)
+
+// CloudEvents 1.0 Kafka protocol binding, binary content mode. Aggregate
+// version and schema ID travel as extension attributes.
+const (
+	CloudEventsSpecVersion = "1.0"
+
+	HeaderCEID               = "ce_id"
+	HeaderCESource           = "ce_source"
+	HeaderCEType             = "ce_type"
+	HeaderCESpecVersion      = "ce_specversion"
+	HeaderCETime             = "ce_time"
+	HeaderCESubject          = "ce_subject"
+	HeaderCEAggregateVersion = "ce_aggregateversion"
+	HeaderCESchemaID         = "ce_schemaid"
+	HeaderCEContentType      = "content-type"
+)
+
+// Header formats the producer can write. Consumers read both.
+const (
+	HeaderFormatLegacy      = "legacy"
+	HeaderFormatCloudEvents = "cloudevents"
 )
 
 type Headers struct {
 	ID        uuid.UUID `json:"id"`
 	Timestamp string    `json:"timestamp"` // Use string for JSON serialization
+	// AggregateID identifies the entity the event belongs to and is used as
+	// the partition key, so all events of one aggregate stay in order.
+	AggregateID string `json:"aggregate_id,omitempty"`
+	// AggregateVersion increases by one with every event of the aggregate; 0
+	// means the version is unknown.
+	AggregateVersion int64 `json:"aggregate_version,omitempty"`
+
+	// Set by the producer when the message is written
+	EventType   string `json:"event_type,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	SchemaID    int64  `json:"schema_id,omitempty"`
+	Source      string `json:"source,omitempty"`
 }
 
 func (e *Headers) ToJSON() ([]byte, error) {
 	return json.Marshal(e)
 }
+
+// ToKafka renders the headers in the given format; anything but
+// HeaderFormatCloudEvents uses the legacy keys.
+func (e *Headers) ToKafka(format string) []kafka.Header {
+	if format == HeaderFormatCloudEvents {
+		return e.cloudEventsHeaders()
+	}
+
+	headers := []kafka.Header{
+		{Key: HeaderEventID, Value: []byte(e.ID.String())},
+		{Key: HeaderTimestamp, Value: []byte(e.Timestamp)},
+		{Key: HeaderEventType, Value: []byte(e.EventType)},
+		{Key: HeaderContentType, Value: []byte(e.ContentType)},
+	}
+	if e.AggregateID != "" {
+		headers = append(headers, kafka.Header{Key: HeaderAggregateID, Value: []byte(e.AggregateID)})
+	}
+	if e.AggregateVersion > 0 {
+		headers = append(headers, kafka.Header{Key: HeaderAggregateVersion, Value: []byte(strconv.FormatInt(e.AggregateVersion, 10))})
+	}
+	if e.SchemaID > 0 {
+		headers = append(headers, kafka.Header{Key: HeaderSchemaID, Value: []byte(strconv.FormatInt(e.SchemaID, 10))})
+	}
+	return headers
+}
+
+func (e *Headers) cloudEventsHeaders() []kafka.Header {
+	headers := []kafka.Header{
+		{Key: HeaderCEID, Value: []byte(e.ID.String())},
+		{Key: HeaderCESource, Value: []byte(e.Source)},
+		{Key: HeaderCEType, Value: []byte(e.EventType)},
+		{Key: HeaderCESpecVersion, Value: []byte(CloudEventsSpecVersion)},
+		{Key: HeaderCEContentType, Value: []byte(e.ContentType)},
+	}
+	if seconds, err := strconv.ParseInt(e.Timestamp, 10, 64); err == nil {
+		headers = append(headers, kafka.Header{Key: HeaderCETime, Value: []byte(time.Unix(seconds, 0).UTC().Format(time.RFC3339))})
+	}
+	if e.AggregateID != "" {
+		headers = append(headers, kafka.Header{Key: HeaderCESubject, Value: []byte(e.AggregateID)})
+	}
+	if e.AggregateVersion > 0 {
+		headers = append(headers, kafka.Header{Key: HeaderCEAggregateVersion, Value: []byte(strconv.FormatInt(e.AggregateVersion, 10))})
+	}
+	if e.SchemaID > 0 {
+		headers = append(headers, kafka.Header{Key: HeaderCESchemaID, Value: []byte(strconv.FormatInt(e.SchemaID, 10))})
+	}
+	return headers
+}
+
+// ParseHeaders flattens Kafka headers into a map. CloudEvents attributes are
+// also exposed under the legacy keys, so handlers can read event_id,
+// event_type, content_type etc. whichever format the producer used.
+func ParseHeaders(kafkaHeaders []kafka.Header) map[string]string {
+	headers := make(map[string]string, len(kafkaHeaders))
+	for _, header := range kafkaHeaders {
+		headers[header.Key] = string(header.Value)
+	}
+
+	if _, ok := headers[HeaderCESpecVersion]; !ok {
+		return headers
+	}
+
+	aliases := map[string]string{
+		HeaderCEID:               HeaderEventID,
+		HeaderCEType:             HeaderEventType,
+		HeaderCEContentType:      HeaderContentType,
+		HeaderCESubject:          HeaderAggregateID,
+		HeaderCEAggregateVersion: HeaderAggregateVersion,
+		HeaderCESchemaID:         HeaderSchemaID,
+	}
+	for ceKey, legacyKey := range aliases {
+		if value, ok := headers[ceKey]; ok {
+			if _, exists := headers[legacyKey]; !exists {
+				headers[legacyKey] = value
+			}
+		}
+	}
+	if ceTime, ok := headers[HeaderCETime]; ok {
+		if _, exists := headers[HeaderTimestamp]; !exists {
+			if t, err := time.Parse(time.RFC3339Nano, ceTime); err == nil {
+				headers[HeaderTimestamp] = strconv.FormatInt(t.Unix(), 10)
+			}
+		}
+	}
+	return headers
+}
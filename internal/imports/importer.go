@@ -0,0 +1,274 @@
+// Package imports runs bulk user imports from NDJSON or CSV uploads as
+// background jobs whose progress is stored in the import_jobs table.
+package imports
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kitamersion/go-goservice/internal/config"
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
+	"github.com/kitamersion/go-goservice/internal/domain/entities"
+	"github.com/kitamersion/go-goservice/internal/domain/repositories"
+	"github.com/kitamersion/go-goservice/internal/domain/services"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultBatchSize    = 500
+	maxBatchSize        = 5000 // keeps a batch INSERT below Postgres' bind parameter limit
+	defaultQueueSize    = 16
+	defaultMaxRowErrors = 1000
+)
+
+type queuedJob struct {
+	record *entities.ImportJob
+	file   *os.File
+}
+
+// Importer runs queued import jobs one at a time. Uploads are spooled to a
+// temporary file by the caller, so the request can return as soon as the job
+// is queued. Jobs live in the process that accepted the upload: if it stops,
+// queued and running jobs are marked as failed.
+type Importer struct {
+	users        *services.UserService
+	jobs         repositories.ImportJobRepository
+	queue        chan queuedJob
+	batchSize    int
+	maxRowErrors int
+	logger       *logrus.Logger
+}
+
+func NewImporter(users *services.UserService, jobs repositories.ImportJobRepository, cfg *config.ImportConfig, logger *logrus.Logger) *Importer {
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	if batchSize > maxBatchSize {
+		batchSize = maxBatchSize
+	}
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	maxRowErrors := cfg.MaxRowErrors
+	if maxRowErrors <= 0 {
+		maxRowErrors = defaultMaxRowErrors
+	}
+
+	return &Importer{
+		users:        users,
+		jobs:         jobs,
+		queue:        make(chan queuedJob, queueSize),
+		batchSize:    batchSize,
+		maxRowErrors: maxRowErrors,
+		logger:       logger,
+	}
+}
+
+// Enqueue records a job for the spooled upload and queues it. The importer
+// takes ownership of file and removes it once the job is done; on error the
+// caller still owns it.
+func (i *Importer) Enqueue(format string, file *os.File) (*entities.ImportJob, error) {
+	record := &entities.ImportJob{
+		ID:        uuid.New(),
+		Format:    format,
+		Status:    entities.ImportJobQueued,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := i.jobs.Create(record); err != nil {
+		return nil, err
+	}
+
+	select {
+	case i.queue <- queuedJob{record: record, file: file}:
+		return record, nil
+	default:
+		i.fail(record, errors.New("import queue is full"))
+		return nil, apperrors.Unavailable("import queue is full, retry later", nil)
+	}
+}
+
+// Job returns the current state of an import job.
+func (i *Importer) Job(id uuid.UUID) (*entities.ImportJob, error) {
+	return i.jobs.GetByID(id)
+}
+
+// Start runs queued jobs until ctx is cancelled. Jobs still waiting in the
+// queue at that point are marked as failed.
+func (i *Importer) Start(ctx context.Context) error {
+	i.logger.Info("Starting user importer")
+	for {
+		select {
+		case <-ctx.Done():
+			i.drain()
+			return ctx.Err()
+		case job := <-i.queue:
+			i.run(ctx, job)
+		}
+	}
+}
+
+func (i *Importer) drain() {
+	for {
+		select {
+		case job := <-i.queue:
+			i.fail(job.record, errors.New("server shut down before the import started"))
+			removeFile(job.file, i.logger)
+		default:
+			return
+		}
+	}
+}
+
+func (i *Importer) run(ctx context.Context, job queuedJob) {
+	defer removeFile(job.file, i.logger)
+
+	record := job.record
+	logger := i.logger.WithField("job_id", record.ID.String())
+	logger.Info("Starting user import")
+
+	record.Status = entities.ImportJobRunning
+	if err := i.save(record); err != nil {
+		logger.WithError(err).Error("Failed to update import job")
+	}
+
+	if err := i.importRows(ctx, record, job.file); err != nil {
+		logger.WithError(err).Error("User import failed")
+		i.fail(record, err)
+		return
+	}
+
+	now := time.Now()
+	record.Status = entities.ImportJobCompleted
+	record.FinishedAt = &now
+	if err := i.save(record); err != nil {
+		logger.WithError(err).Error("Failed to update import job")
+	}
+	logger.WithFields(logrus.Fields{
+		"processed": record.Processed,
+		"succeeded": record.Succeeded,
+		"failed":    record.Failed,
+	}).Info("User import completed")
+}
+
+// importRows reads the upload and creates users batch by batch, saving the
+// job's progress after each batch. Invalid rows are recorded and skipped; an
+// error stops the job, keeping the users of batches that already committed.
+func (i *Importer) importRows(ctx context.Context, record *entities.ImportJob, file io.Reader) error {
+	reader, err := NewReader(record.Format, file)
+	if err != nil {
+		return err
+	}
+
+	batch := make([]Row, 0, i.batchSize)
+	for {
+		if ctx.Err() != nil {
+			return errors.New("import interrupted by server shutdown")
+		}
+
+		row, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		batch = append(batch, row)
+		if len(batch) == i.batchSize {
+			if err := i.importBatch(ctx, record, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	return i.importBatch(ctx, record, batch)
+}
+
+func (i *Importer) importBatch(ctx context.Context, record *entities.ImportJob, rows []Row) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	users := make([]*entities.UserEntity, 0, len(rows))
+	rowOf := make(map[*entities.UserEntity]int, len(rows))
+	for _, row := range rows {
+		if row.Err != nil {
+			i.addRowError(record, row.Number, row.Err)
+			continue
+		}
+		user := &entities.UserEntity{
+			Name:  row.Request.Name,
+			Email: row.Request.Email,
+		}
+		users = append(users, user)
+		rowOf[user] = row.Number
+	}
+
+	created, err := i.users.CreateUsers(ctx, users)
+	if err != nil {
+		return err
+	}
+
+	createdSet := make(map[*entities.UserEntity]bool, len(created))
+	for _, user := range created {
+		createdSet[user] = true
+	}
+	for _, user := range users {
+		if !createdSet[user] {
+			i.addRowError(record, rowOf[user], apperrors.Conflict("a user with this email already exists"))
+		}
+	}
+
+	record.Processed += len(rows)
+	record.Succeeded += len(created)
+	record.Failed = record.Processed - record.Succeeded
+	return i.save(record)
+}
+
+// addRowError keeps up to maxRowErrors errors per job; the failed counter
+// still covers every rejected row.
+func (i *Importer) addRowError(record *entities.ImportJob, row int, err error) {
+	fields := apperrors.FieldsOf(err)
+	if len(fields) == 0 {
+		fields = []apperrors.FieldError{{Message: apperrors.Message(err, err.Error())}}
+	}
+	for _, field := range fields {
+		if len(record.RowErrors) >= i.maxRowErrors {
+			return
+		}
+		record.RowErrors = append(record.RowErrors, entities.ImportRowError{
+			Row:     row,
+			Field:   field.Field,
+			Message: field.Message,
+		})
+	}
+}
+
+func (i *Importer) fail(record *entities.ImportJob, err error) {
+	now := time.Now()
+	record.Status = entities.ImportJobFailed
+	record.Error = apperrors.Message(err, err.Error())
+	record.FinishedAt = &now
+	if err := i.save(record); err != nil {
+		i.logger.WithError(err).WithField("job_id", record.ID.String()).Error("Failed to update import job")
+	}
+}
+
+func (i *Importer) save(record *entities.ImportJob) error {
+	record.UpdatedAt = time.Now()
+	return i.jobs.Update(record)
+}
+
+func removeFile(file *os.File, logger *logrus.Logger) {
+	file.Close()
+	if err := os.Remove(file.Name()); err != nil {
+		logger.WithError(err).Warn("Failed to remove import upload")
+	}
+}
@@ -0,0 +1,153 @@
+package imports
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/kitamersion/go-goservice/internal/api/dto"
+	"github.com/kitamersion/go-goservice/internal/domain/apperrors"
+)
+
+// Supported upload formats.
+const (
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+const maxLineBytes = 1 << 20
+
+// FormatFromContentType maps a request Content-Type to an import format.
+func FormatFromContentType(contentType string) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return FormatNDJSON, nil
+	case "text/csv":
+		return FormatCSV, nil
+	}
+	return "", apperrors.Validation("Content-Type must be application/x-ndjson or text/csv")
+}
+
+// Row is one parsed and validated input row. Err is set instead of Request
+// when the row is invalid; such rows are reported and skipped.
+type Row struct {
+	Number  int
+	Request dto.CreateUserRequest
+	Err     error
+}
+
+// Reader yields rows until it returns io.EOF. Any other error means the input
+// cannot be read any further and fails the whole import.
+type Reader interface {
+	Next() (Row, error)
+}
+
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+		return &ndjsonReader{scanner: scanner}, nil
+	case FormatCSV:
+		return newCSVReader(r)
+	}
+	return nil, fmt.Errorf("unsupported import format %q", format)
+}
+
+// ndjsonReader reads one user object per line; blank lines are skipped. Each
+// line goes through the same decoding and validation as POST /users.
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	row     int
+}
+
+func (r *ndjsonReader) Next() (Row, error) {
+	for r.scanner.Scan() {
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		r.row++
+
+		row := Row{Number: r.row}
+		row.Err = dto.Decode(bytes.NewReader(line), &row.Request)
+		return row, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Row{}, fmt.Errorf("failed to read line %d: %w", r.row+1, err)
+	}
+	return Row{}, io.EOF
+}
+
+// csvReader expects a header row naming the name and email columns, in any
+// order; other columns are rejected like unknown JSON fields.
+type csvReader struct {
+	reader *csv.Reader
+	name   int
+	email  int
+	row    int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, apperrors.Validation("CSV input is empty")
+	}
+	if err != nil {
+		return nil, apperrors.New(apperrors.KindValidation, "CSV header cannot be parsed", err)
+	}
+
+	c := &csvReader{reader: reader, name: -1, email: -1}
+	for i, column := range header {
+		switch strings.ToLower(strings.TrimSpace(column)) {
+		case "name":
+			c.name = i
+		case "email":
+			c.email = i
+		default:
+			return nil, apperrors.Validation(fmt.Sprintf("unknown CSV column %q", column))
+		}
+	}
+	if c.name < 0 || c.email < 0 {
+		return nil, apperrors.Validation("CSV header must contain name and email columns")
+	}
+	return c, nil
+}
+
+func (c *csvReader) Next() (Row, error) {
+	record, err := c.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return Row{}, io.EOF
+	}
+	c.row++
+	row := Row{Number: c.row}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount) {
+		row.Err = apperrors.Validation(fmt.Sprintf("expected %d columns, got %d", c.reader.FieldsPerRecord, len(record)))
+		return row, nil
+	}
+	if err != nil {
+		return Row{}, fmt.Errorf("failed to read CSV row %d: %w", c.row, err)
+	}
+
+	row.Request = dto.CreateUserRequest{
+		Name:  record[c.name],
+		Email: record[c.email],
+	}
+	row.Request.Normalize()
+	row.Err = row.Request.Validate()
+	return row, nil
+}
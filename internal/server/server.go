@@ -0,0 +1,163 @@
+// Package server runs an HTTP server together with the background workers of
+// a process, and shuts everything down in order on SIGINT or SIGTERM.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kitamersion/go-goservice/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultReadTimeout       = 30 * time.Second
+	defaultWriteTimeout      = 30 * time.Second
+	defaultIdleTimeout       = 120 * time.Second
+	defaultShutdownTimeout   = 20 * time.Second
+)
+
+// NewHTTPServer returns a server listening on addr with the timeouts of cfg.
+func NewHTTPServer(cfg *config.ServerConfig, addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: orDefault(cfg.ReadHeaderTimeout, defaultReadHeaderTimeout),
+		ReadTimeout:       orDefault(cfg.ReadTimeout, defaultReadTimeout),
+		WriteTimeout:      orDefault(cfg.WriteTimeout, defaultWriteTimeout),
+		IdleTimeout:       orDefault(cfg.IdleTimeout, defaultIdleTimeout),
+	}
+}
+
+type closer struct {
+	name  string
+	close func() error
+}
+
+// Lifecycle owns the background workers and resources of a process. Shutdown
+// happens in a fixed order: the HTTP server drains in-flight requests, then
+// workers are cancelled and awaited, then closers run in the order they were
+// added, e.g. the Kafka producer (flushing buffered events) before the DB pool.
+type Lifecycle struct {
+	ctx             context.Context
+	cancel          context.CancelFunc
+	workers         sync.WaitGroup
+	closers         []closer
+	shutdownTimeout time.Duration
+	logger          *logrus.Logger
+}
+
+func NewLifecycle(cfg *config.ServerConfig, logger *logrus.Logger) *Lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Lifecycle{
+		ctx:             ctx,
+		cancel:          cancel,
+		shutdownTimeout: orDefault(cfg.ShutdownTimeout, defaultShutdownTimeout),
+		logger:          logger,
+	}
+}
+
+// Go starts a background worker. Its context is cancelled once the HTTP
+// server has drained, and shutdown waits for it to return. context.Canceled
+// is treated as a clean stop.
+func (l *Lifecycle) Go(name string, worker func(ctx context.Context) error) {
+	l.workers.Add(1)
+	go func() {
+		defer l.workers.Done()
+		if err := worker(l.ctx); err != nil && !errors.Is(err, context.Canceled) {
+			l.logger.WithError(err).WithField("worker", name).Error("Background worker stopped with error")
+		}
+	}()
+}
+
+// OnShutdown adds a resource to close once every worker has stopped.
+func (l *Lifecycle) OnShutdown(name string, close func() error) {
+	l.closers = append(l.closers, closer{name: name, close: close})
+}
+
+// Run serves srv until SIGINT/SIGTERM or a server failure, then shuts down.
+// It returns the server error, if any; shutdown problems are only logged.
+func (l *Lifecycle) Run(srv *http.Server) error {
+	signals, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		l.logger.Info("Listening on ", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- fmt.Errorf("server failed: %w", err)
+		}
+		close(serveErr)
+	}()
+
+	var err error
+	select {
+	case <-signals.Done():
+		l.logger.Info("Shutdown signal received")
+	case err = <-serveErr:
+	}
+	stop() // a second signal kills the process right away
+
+	l.shutdown(srv)
+	return err
+}
+
+func (l *Lifecycle) shutdown(srv *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), l.shutdownTimeout)
+	defer cancel()
+
+	l.logger.Info("Draining HTTP connections")
+	if err := srv.Shutdown(ctx); err != nil {
+		l.logger.WithError(err).Warn("HTTP server did not drain before the deadline")
+	}
+
+	l.logger.Info("Stopping background workers")
+	l.cancel()
+	stopped := make(chan struct{})
+	go func() {
+		l.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		l.logger.Warn("Background workers did not stop before the deadline")
+	}
+
+	for _, c := range l.closers {
+		l.logger.Info("Closing ", c.name)
+		if err := c.close(); err != nil {
+			l.logger.WithError(err).Errorf("Failed to close %s", c.name)
+		}
+	}
+	l.logger.Info("Shutdown complete")
+}
+
+func orDefault(d, fallback time.Duration) time.Duration {
+	if d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+// AllowWebSockets lifts the server's read and write timeouts for WebSocket
+// upgrades, which stay open for as long as their subscriptions run and are
+// kept in check by pings instead.
+func AllowWebSockets(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			rc := http.NewResponseController(w)
+			_ = rc.SetReadDeadline(time.Time{})
+			_ = rc.SetWriteDeadline(time.Time{})
+		}
+		next.ServeHTTP(w, r)
+	})
+}